@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/TouchBistro/goutils/file"
 	"github.com/TouchBistro/goutils/text"
@@ -18,17 +19,35 @@ import (
 // Action represents an action that can be applied.
 //
 // The Run method applies the action to a given target
-// and returns a string describing the result.
+// and returns a Result describing what was done.
 //
 // Actions should not mutate themselves or have side effects since they are intended
 // to be run multiple times with different targets, possible on different goroutines.
 //
 // The String method provides a string description of the action.
 type Action interface {
-	Run(ctx context.Context, t Target, args Arguments) (string, error)
+	Run(ctx context.Context, t Target, args Arguments) (Result, error)
 	String() string
 }
 
+// Result describes the outcome of running an Action on a target.
+type Result struct {
+	// Message is a human readable description of what the action did.
+	Message string `json:"message"`
+	// Detail is optional markdown that provides more information about
+	// what the action did, for example the output of a command.
+	Detail string `json:"detail,omitempty"`
+	// Files contains the paths of the files the action operated on,
+	// relative to the target root.
+	Files []string `json:"files,omitempty"`
+	// Matches is the number of matches found by actions that search
+	// for something, such as text actions. It is always 0 for other actions.
+	Matches int `json:"matches"`
+	// Changed reports whether the action modified the target.
+	// Actions that cannot determine this, like command actions, always report true.
+	Changed bool `json:"changed"`
+}
+
 // Target represents an item that an Action will be applied to.
 type Target interface {
 	Path() string
@@ -53,6 +72,11 @@ type Config struct {
 	// The path to the file in a text file.
 	// Must be relative to the target root.
 	Path string `yaml:"path"`
+	// Causes a text action to fail if the search text did not match anything.
+	Required bool `yaml:"required"`
+	// The exact number of matches a text action must find, otherwise it fails.
+	// Implies required. A value of 0 means any number of matches is allowed.
+	ExpectMatches int `yaml:"expectMatches"`
 
 	// The source file to use in a file action.
 	SrcPath string `yaml:"srcPath"`
@@ -62,6 +86,33 @@ type Config struct {
 
 	// The command to run in a command action.
 	Run string `yaml:"run"`
+
+	// The key to operate on in a YAML or JSON action, as a dotted path like services.api.image.
+	// Numeric segments are indices into sequences. JSON actions also accept a JSON Pointer
+	// like /compilerOptions/target.
+	// In an env var action it is the name of the variable and in a TOML or INI action
+	// it is the name of the key within Section.
+	Key string `yaml:"key"`
+	// The value to set in a YAML, JSON, env var, TOML or INI action.
+	// Can be any YAML value including mappings and sequences, however env var and INI
+	// actions only support strings, numbers and booleans.
+	Value any `yaml:"value"`
+	// The section containing the key in a TOML or INI action, like tool.poetry.
+	// If empty, the key is outside of any section at the top of the file.
+	Section string `yaml:"section"`
+
+	// The name of the dependency to bump in a dependency action.
+	Dependency string `yaml:"dependency"`
+	// The version to bump the dependency to in a dependency action.
+	// In a go.mod action it is the version of Module to require or the Go version to set.
+	Version string `yaml:"version"`
+
+	// The module path in a go.mod action. For replace actions it may include
+	// a version like path@version to only replace that version.
+	Module string `yaml:"module"`
+	// The module to replace Module with in a go.mod replace action.
+	// Either path@version or a local directory like ../fork.
+	Replacement string `yaml:"replacement"`
 }
 
 // Parse parses a config that describes an action and returns an Action.
@@ -73,11 +124,33 @@ func Parse(cfg Config) (Action, error) {
 		return parseFileAction(cfg)
 	case strings.HasSuffix(cfg.Type, "Command"):
 		return parseCommandAction(cfg)
+	case strings.HasSuffix(cfg.Type, "YAML"):
+		return parseYAMLAction(cfg)
+	case strings.HasSuffix(cfg.Type, "JSON"):
+		return parseJSONAction(cfg)
+	case strings.HasSuffix(cfg.Type, "EnvVar"):
+		return parseEnvAction(cfg)
+	case strings.HasSuffix(cfg.Type, "TOML") || strings.HasSuffix(cfg.Type, "INI"):
+		return parseINIAction(cfg)
+	case strings.HasSuffix(cfg.Type, "Dependency"):
+		return parseDependencyAction(cfg)
+	case strings.HasPrefix(cfg.Type, "goMod"):
+		return parseGoModAction(cfg)
 	default:
 		return nil, fmt.Errorf("unsupported action type %s", cfg.Type)
 	}
 }
 
+// expand expands the variables in s.
+func expand(s string, args Arguments) (string, error) {
+	vm := text.NewVariableMapper(args.Variables)
+	expanded := text.ExpandVariables([]byte(s), vm.Map)
+	if len(vm.Missing()) > 0 {
+		return "", fmt.Errorf("failed to expand variables in %s, unknown variables %q", s, strings.Join(vm.Missing(), ", "))
+	}
+	return string(expanded), nil
+}
+
 func parseTextAction(cfg Config) (Action, error) {
 	// Path and Target are always required
 	if cfg.Path == "" {
@@ -87,7 +160,16 @@ func parseTextAction(cfg Config) (Action, error) {
 		return nil, errors.New("missing search text for text action")
 	}
 
-	a := textAction{searchText: []byte(cfg.SearchText), path: cfg.Path}
+	if cfg.ExpectMatches < 0 {
+		return nil, fmt.Errorf("invalid expectMatches %d for text action, must not be negative", cfg.ExpectMatches)
+	}
+
+	a := textAction{
+		searchText:    []byte(cfg.SearchText),
+		path:          cfg.Path,
+		required:      cfg.Required || cfg.ExpectMatches > 0,
+		expectMatches: cfg.ExpectMatches,
+	}
 	switch cfg.Type {
 	case "replaceLine":
 		a.typ = textReplaceLine
@@ -178,17 +260,20 @@ type textAction struct {
 	searchText []byte // text that will be matched; it's a regex
 	applyText  []byte // text that will be applied in non-delete types
 	path       string
+
+	required      bool // whether at least one match is required
+	expectMatches int  // exact number of matches required; 0 means any number
 }
 
-func (a textAction) Run(_ context.Context, t Target, args Arguments) (string, error) {
+func (a textAction) Run(_ context.Context, t Target, args Arguments) (Result, error) {
 	vm := text.NewVariableMapper(args.Variables)
 	searchText := text.ExpandVariables(a.searchText, vm.Map)
 	if len(vm.Missing()) > 0 {
-		return "", fmt.Errorf("failed to expand variables in action target, unknown variables %q", strings.Join(vm.Missing(), ", "))
+		return Result{}, fmt.Errorf("failed to expand variables in action target, unknown variables %q", strings.Join(vm.Missing(), ", "))
 	}
 	applyText := text.ExpandVariables(a.applyText, vm.Map)
 	if len(vm.Missing()) > 0 {
-		return "", fmt.Errorf("failed to expand variables in action source, unknown variables %q", strings.Join(vm.Missing(), ", "))
+		return Result{}, fmt.Errorf("failed to expand variables in action source, unknown variables %q", strings.Join(vm.Missing(), ", "))
 	}
 	// Enable multi-line mode by adding flag if not a line action
 	// https://golang.org/pkg/regexp/syntax/
@@ -198,23 +283,25 @@ func (a textAction) Run(_ context.Context, t Target, args Arguments) (string, er
 	}
 	regex, err := regexp.Compile(regexStr)
 	if err != nil {
-		return "", fmt.Errorf("unable to compile regex from action target: %w", err)
+		return Result{}, fmt.Errorf("unable to compile regex from action target: %w", err)
 	}
 
 	path := filepath.Join(t.Path(), a.path)
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file %s: %w", path, err)
+		return Result{}, fmt.Errorf("failed to read file %s: %w", path, err)
 	}
 
 	var output []byte
 	var msg string
+	var matches int
 	switch a.typ {
 	case textReplaceLine:
 		lines := bytes.Split(data, []byte{'\n'})
 		for i, line := range lines {
 			if regex.Match(line) {
 				lines[i] = applyText
+				matches++
 			}
 		}
 		output = bytes.Join(lines, []byte{'\n'})
@@ -224,17 +311,21 @@ func (a textAction) Run(_ context.Context, t Target, args Arguments) (string, er
 		var filtered [][]byte
 		// Filter all lines that match the line to delete
 		for _, line := range lines {
-			if !regex.Match(line) {
-				filtered = append(filtered, line)
+			if regex.Match(line) {
+				matches++
+				continue
 			}
+			filtered = append(filtered, line)
 		}
 		output = bytes.Join(filtered, []byte{'\n'})
 		msg = fmt.Sprintf("Deleted line `%s` in `%s`", searchText, a.path)
 	case textReplace:
+		matches = len(regex.FindAllIndex(data, -1))
 		output = regex.ReplaceAll(data, applyText)
 		msg = fmt.Sprintf("Replaced text `%s` with `%s` in `%s`", searchText, applyText, a.path)
 	case textAppend:
 		output = regex.ReplaceAllFunc(data, func(m []byte) []byte {
+			matches++
 			// Make sure we copy m and don't mutate it since it is a slice of data
 			out := append([]byte{}, m...)
 			return append(out, applyText...)
@@ -244,6 +335,7 @@ func (a textAction) Run(_ context.Context, t Target, args Arguments) (string, er
 		// Get a slice of all substrings that don't match regex
 		// TODO(@cszatmary): Could optimize this by doing the split ourselves so we don't
 		// need to convert it to a string first.
+		matches = len(regex.FindAllIndex(data, -1))
 		parts := regex.Split(string(data), -1)
 		for _, p := range parts {
 			output = append(output, p...)
@@ -253,27 +345,48 @@ func (a textAction) Run(_ context.Context, t Target, args Arguments) (string, er
 		panic("impossible: invalid type")
 	}
 
+	if matches == 0 {
+		msg = fmt.Sprintf("No matches found for `%s` in `%s`", searchText, a.path)
+	}
+	if a.required && matches == 0 {
+		return Result{}, fmt.Errorf("no matches found for %q in %s", searchText, path)
+	}
+	if a.expectMatches > 0 && matches != a.expectMatches {
+		return Result{}, fmt.Errorf("expected %d matches for %q in %s, found %d", a.expectMatches, searchText, path, matches)
+	}
 	if err := os.WriteFile(path, output, 0o644); err != nil {
-		return "", fmt.Errorf("failed to write file %s: %w", path, err)
+		return Result{}, fmt.Errorf("failed to write file %s: %w", path, err)
 	}
-	return msg, nil
+	return Result{
+		Message: msg,
+		Files:   []string{a.path},
+		Matches: matches,
+		Changed: !bytes.Equal(data, output),
+	}, nil
 }
 
 func (a textAction) String() string {
+	var s string
 	switch a.typ {
 	case textReplaceLine:
-		return fmt.Sprintf("replace line: %q\n  with: %q\n  path: %q", a.searchText, a.applyText, a.path)
+		s = fmt.Sprintf("replace line: %q\n  with: %q\n  path: %q", a.searchText, a.applyText, a.path)
 	case textDeleteLine:
-		return fmt.Sprintf("delete line: %q\n  path: %q", a.searchText, a.path)
+		s = fmt.Sprintf("delete line: %q\n  path: %q", a.searchText, a.path)
 	case textReplace:
-		return fmt.Sprintf("replace text: %q\n  with: %q\n  path: %q", a.searchText, a.applyText, a.path)
+		s = fmt.Sprintf("replace text: %q\n  with: %q\n  path: %q", a.searchText, a.applyText, a.path)
 	case textAppend:
-		return fmt.Sprintf("append text: %q\n  to: %q\n  path: %q", a.applyText, a.searchText, a.path)
+		s = fmt.Sprintf("append text: %q\n  to: %q\n  path: %q", a.applyText, a.searchText, a.path)
 	case textDelete:
-		return fmt.Sprintf("delete text: %q\n  path: %q", a.searchText, a.path)
+		s = fmt.Sprintf("delete text: %q\n  path: %q", a.searchText, a.path)
 	default:
 		panic("impossible: invalid type")
 	}
+	if a.expectMatches > 0 {
+		s += fmt.Sprintf("\n  expect matches: %d", a.expectMatches)
+	} else if a.required {
+		s += "\n  required: true"
+	}
+	return s
 }
 
 type fileActionType int
@@ -293,39 +406,53 @@ type fileAction struct {
 	data []byte // src data; cached so it can be reused each run
 }
 
-func (a fileAction) Run(_ context.Context, t Target, args Arguments) (string, error) {
+func (a fileAction) Run(_ context.Context, t Target, args Arguments) (Result, error) {
 	dstPath := filepath.Join(t.Path(), a.dst)
 	exists := file.Exists(dstPath)
 	switch a.typ {
 	case fileCreate:
 		if exists {
-			return "", fmt.Errorf("file %s already exists", dstPath)
+			return Result{}, fmt.Errorf("file %s already exists", dstPath)
 		}
 	case fileReplace, fileDelete:
 		if !exists {
-			return "", fmt.Errorf("file %s does not exist", dstPath)
+			return Result{}, fmt.Errorf("file %s does not exist", dstPath)
 		}
 	}
 
 	if a.typ == fileDelete {
 		if err := os.Remove(dstPath); err != nil {
-			return "", fmt.Errorf("failed to delete file %s: %w", dstPath, err)
+			return Result{}, fmt.Errorf("failed to delete file %s: %w", dstPath, err)
 		}
-		return fmt.Sprintf("Deleted file `%s`", a.dst), nil
+		return Result{
+			Message: fmt.Sprintf("Deleted file `%s`", a.dst),
+			Files:   []string{a.dst},
+			Changed: true,
+		}, nil
 	}
 
 	vm := text.NewVariableMapper(args.Variables)
 	data := text.ExpandVariables(a.data, vm.Map)
 	if len(vm.Missing()) > 0 {
-		return "", fmt.Errorf("failed to expand variables in file %s, unknown variables %q", a.src, strings.Join(vm.Missing(), ", "))
+		return Result{}, fmt.Errorf("failed to expand variables in file %s, unknown variables %q", a.src, strings.Join(vm.Missing(), ", "))
 	}
-	if err := os.WriteFile(dstPath, data, 0o644); err != nil {
-		return "", fmt.Errorf("failed to write file %s: %w", dstPath, err)
+	res := Result{
+		Message: fmt.Sprintf("Created file `%s`", a.dst),
+		Files:   []string{a.dst},
+		Changed: true,
 	}
 	if exists {
-		return fmt.Sprintf("Replaced file `%s`", a.dst), nil
+		existing, err := os.ReadFile(dstPath)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to read file %s: %w", dstPath, err)
+		}
+		res.Message = fmt.Sprintf("Replaced file `%s`", a.dst)
+		res.Changed = !bytes.Equal(existing, data)
+	}
+	if err := os.WriteFile(dstPath, data, 0o644); err != nil {
+		return Result{}, fmt.Errorf("failed to write file %s: %w", dstPath, err)
 	}
-	return fmt.Sprintf("Created file `%s`", a.dst), nil
+	return res, nil
 }
 
 func (a fileAction) String() string {
@@ -349,17 +476,56 @@ type commandAction struct {
 	str  string   // the command string from the config; for printing
 }
 
-func (a commandAction) Run(ctx context.Context, t Target, _ Arguments) (string, error) {
-	var errbuf bytes.Buffer
+func (a commandAction) Run(ctx context.Context, t Target, _ Arguments) (Result, error) {
+	var outbuf, errbuf bytes.Buffer
 	cmd := exec.CommandContext(ctx, a.args[0], a.args[1:]...)
+	cmd.Stdout = &outbuf
 	cmd.Stderr = &errbuf
 	cmd.Dir = t.Path()
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("failed to run command %s at %s: %s: %w", a.str, t.Path(), errbuf.String(), err)
+		return Result{}, fmt.Errorf("failed to run command %s at %s: %s: %w", a.str, t.Path(), errbuf.String(), err)
+	}
+	// There's no way to know what a command did so always assume it changed something.
+	res := Result{Message: fmt.Sprintf("Ran command `%s`", a.str), Changed: true}
+	if out := strings.TrimSpace(outbuf.String()); out != "" {
+		res.Detail = "```\n" + truncateOutput(out) + "\n```"
 	}
-	return fmt.Sprintf("Ran command `%s`", a.str), nil
+	return res, nil
 }
 
 func (a commandAction) String() string {
 	return fmt.Sprintf("run: %s", a.str)
 }
+
+const (
+	maxOutputLines = 20
+	maxOutputBytes = 2000
+)
+
+// truncateOutput returns the end of the output of a command so that it stays readable
+// and doesn't push PR descriptions over the size limit of the GitHub API.
+func truncateOutput(out string) string {
+	lines := strings.Split(out, "\n")
+	omitted := 0
+	if len(lines) > maxOutputLines {
+		omitted = len(lines) - maxOutputLines
+		lines = lines[omitted:]
+	}
+	// Drop whole lines first, then cut the last line if it is still too long.
+	for len(lines) > 1 && len(strings.Join(lines, "\n")) > maxOutputBytes {
+		lines = lines[1:]
+		omitted++
+	}
+	out = strings.Join(lines, "\n")
+	if len(out) > maxOutputBytes {
+		i := len(out) - maxOutputBytes
+		for i < len(out) && !utf8.RuneStart(out[i]) {
+			i++
+		}
+		out = "..." + out[i:]
+	}
+	if omitted > 0 {
+		out = fmt.Sprintf("... %d lines omitted\n%s", omitted, out)
+	}
+	return out
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/TouchBistro/cannon/action"
@@ -25,12 +27,13 @@ This section is pretty hype.
 
 func TestTextAction(t *testing.T) {
 	tests := []struct {
-		name    string
-		in      string
-		cfg     action.Config
-		vars    map[string]string
-		wantMsg string
-		out     string
+		name        string
+		in          string
+		cfg         action.Config
+		vars        map[string]string
+		wantMsg     string
+		wantMatches int
+		out         string
 	}{
 		{
 			name: "replace line",
@@ -41,7 +44,8 @@ func TestTextAction(t *testing.T) {
 				SearchText: "# HYPE ZONE",
 				Path:       "replace_line.md",
 			},
-			wantMsg: "Replaced line `# HYPE ZONE` with `# WOKE ZONE` in `replace_line.md`",
+			wantMsg:     "Replaced line `# HYPE ZONE` with `# WOKE ZONE` in `replace_line.md`",
+			wantMatches: 1,
 			out: `# WOKE ZONE
 This file is ***hype***.
 
@@ -57,7 +61,8 @@ This section is pretty hype.
 				SearchText: "## Hype Section",
 				Path:       "delete_line.md",
 			},
-			wantMsg: "Deleted line `## Hype Section` in `delete_line.md`",
+			wantMsg:     "Deleted line `## Hype Section` in `delete_line.md`",
+			wantMatches: 1,
 			out: `# HYPE ZONE
 This file is ***hype***.
 
@@ -73,7 +78,8 @@ This section is pretty hype.
 				SearchText: "^#.+",
 				Path:       "replace_text.md",
 			},
-			wantMsg: "Replaced text `^#.+` with `*****` in `replace_text.md`",
+			wantMsg:     "Replaced text `^#.+` with `*****` in `replace_text.md`",
+			wantMatches: 2,
 			out: `*****
 This file is ***hype***.
 
@@ -94,7 +100,8 @@ This section is pretty hype.
 				"REPO_OWNER": "TouchBistro",
 				"REPO_NAME":  "node-boilerplate",
 			},
-			wantMsg: "Appended text ` --- TouchBistro - node-boilerplate` to all occurrences of `^#.+` in `append_text.md`",
+			wantMsg:     "Appended text ` --- TouchBistro - node-boilerplate` to all occurrences of `^#.+` in `append_text.md`",
+			wantMatches: 2,
 			out: `# HYPE ZONE --- TouchBistro - node-boilerplate
 This file is ***hype***.
 
@@ -110,10 +117,41 @@ This section is pretty hype.
 				SearchText: `\**hype\**`,
 				Path:       "delete_text.txt",
 			},
-			wantMsg: "Deleted all occurrences of `\\**hype\\**` in `delete_text.txt`",
+			wantMsg:     "Deleted all occurrences of `\\**hype\\**` in `delete_text.txt`",
+			wantMatches: 2,
 			out: `# HYPE ZONE
 This file is .
 
+## Hype Section
+This section is pretty .
+`,
+		},
+		{
+			name: "no matches",
+			in:   inputText,
+			cfg: action.Config{
+				Type:       "replaceLine",
+				ApplyText:  "# WOKE ZONE",
+				SearchText: "# CHILL ZONE",
+				Path:       "no_matches.md",
+			},
+			wantMsg: "No matches found for `# CHILL ZONE` in `no_matches.md`",
+			out:     inputText,
+		},
+		{
+			name: "expected matches",
+			in:   inputText,
+			cfg: action.Config{
+				Type:          "deleteText",
+				SearchText:    "hype",
+				Path:          "expected_matches.md",
+				ExpectMatches: 2,
+			},
+			wantMsg:     "Deleted all occurrences of `hype` in `expected_matches.md`",
+			wantMatches: 2,
+			out: `# HYPE ZONE
+This file is ******.
+
 ## Hype Section
 This section is pretty .
 `,
@@ -132,12 +170,21 @@ This section is pretty .
 				t.Fatalf("unexpected error %v", err)
 			}
 
-			msg, err := a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
 			if err != nil {
 				t.Fatalf("unexpected error %v", err)
 			}
-			if msg != tt.wantMsg {
-				t.Errorf("got message\n\t%s\nwant\n\t%s", msg, tt.wantMsg)
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if res.Matches != tt.wantMatches {
+				t.Errorf("got %d matches, want %d", res.Matches, tt.wantMatches)
+			}
+			if wantChanged := tt.in != tt.out; res.Changed != wantChanged {
+				t.Errorf("got changed %t, want %t", res.Changed, wantChanged)
+			}
+			if len(res.Files) != 1 || res.Files[0] != tt.cfg.Path {
+				t.Errorf("got files %q, want [%q]", res.Files, tt.cfg.Path)
 			}
 
 			// Check that the file was modified correctly
@@ -170,6 +217,27 @@ func TestTextActionError(t *testing.T) {
 				Path:       "invalid_regex.md",
 			},
 		},
+		{
+			name: "required without matches",
+			in:   inputText,
+			cfg: action.Config{
+				Type:       "deleteLine",
+				SearchText: "# CHILL ZONE",
+				Path:       "required.md",
+				Required:   true,
+			},
+		},
+		{
+			name: "wrong number of matches",
+			in:   inputText,
+			cfg: action.Config{
+				Type:          "replaceText",
+				ApplyText:     "chill",
+				SearchText:    "hype",
+				Path:          "wrong_matches.md",
+				ExpectMatches: 1,
+			},
+		},
 	}
 
 	td := t.TempDir()
@@ -250,6 +318,18 @@ func TestFileAction(t *testing.T) {
 			wantMsg: "Replaced file `replace_existing_file.md`",
 			out:     inputText,
 		},
+		{
+			name:     "replace file with same content",
+			in:       inputText,
+			existing: inputText,
+			cfg: action.Config{
+				Type:    "replaceFile",
+				SrcPath: filepath.Join(sd, "replace_same_file.md"),
+				DstPath: "replace_same_file.md",
+			},
+			wantMsg: "Replaced file `replace_same_file.md`",
+			out:     inputText,
+		},
 		{
 			name:     "delete file",
 			existing: `content goes here`,
@@ -283,12 +363,15 @@ func TestFileAction(t *testing.T) {
 				t.Fatalf("unexpected error %v", err)
 			}
 
-			msg, err := a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
 			if err != nil {
 				t.Fatalf("unexpected error %v", err)
 			}
-			if msg != tt.wantMsg {
-				t.Errorf("got message\n\t%s\nwant\n\t%s", msg, tt.wantMsg)
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if wantChanged := tt.existing != tt.out; res.Changed != wantChanged {
+				t.Errorf("got changed %t, want %t", res.Changed, wantChanged)
 			}
 
 			// Check that file was deleted
@@ -312,6 +395,84 @@ func TestFileAction(t *testing.T) {
 	}
 }
 
+func TestCommandAction(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        action.Config
+		wantMsg    string
+		wantDetail string
+	}{
+		{
+			name: "run command",
+			cfg: action.Config{
+				Type: "runCommand",
+				Run:  "echo hype",
+			},
+			wantMsg:    "Ran command `echo hype`",
+			wantDetail: "```\nhype\n```",
+		},
+		{
+			name: "shell command without output",
+			cfg: action.Config{
+				Type: "shellCommand",
+				Run:  "touch hype.txt > /dev/null",
+			},
+			wantMsg: "Ran command `touch hype.txt > /dev/null`",
+		},
+		{
+			name: "truncate long output",
+			cfg: action.Config{
+				Type: "shellCommand",
+				Run:  "seq 1 30",
+			},
+			wantMsg:    "Ran command `seq 1 30`",
+			wantDetail: "```\n... 10 lines omitted\n" + seq(11, 30) + "\n```",
+		},
+		{
+			name: "truncate long line",
+			cfg: action.Config{
+				Type: "shellCommand",
+				Run:  "head -c 3000 /dev/zero | tr '\\0' x",
+			},
+			wantMsg:    "Ran command `head -c 3000 /dev/zero | tr '\\0' x`",
+			wantDetail: "```\n..." + strings.Repeat("x", 2000) + "\n```",
+		},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if res.Detail != tt.wantDetail {
+				t.Errorf("got detail\n\t%s\nwant\n\t%s", res.Detail, tt.wantDetail)
+			}
+			if !res.Changed {
+				t.Error("want command action to report changes")
+			}
+		})
+	}
+}
+
+// seq returns the numbers from first to last on separate lines.
+func seq(first, last int) string {
+	var lines []string
+	for i := first; i <= last; i++ {
+		lines = append(lines, strconv.Itoa(i))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func TestParseError(t *testing.T) {
 	tests := []struct {
 		name string
@@ -326,6 +487,23 @@ func TestParseError(t *testing.T) {
 				Path:    "noop.md",
 			},
 		},
+		{
+			name: "unsupported dependency manifest",
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "express",
+				Version:    "4.18.2",
+				Path:       "yarn.lock",
+			},
+		},
+		{
+			name: "YAML action without value",
+			cfg: action.Config{
+				Type: "setYAML",
+				Key:  "services.api.image",
+				Path: "noop.yml",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,166 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"github.com/TouchBistro/goutils/file"
+	"github.com/TouchBistro/goutils/text"
+)
+
+// Condition is a predicate that a target must satisfy for actions to be run on it.
+//
+// The Match method reports whether the target satisfies the condition.
+// Like actions, conditions must not have side effects since they are
+// evaluated for many targets, possibly on different goroutines.
+//
+// The String method provides a string description of the condition.
+type Condition interface {
+	Match(ctx context.Context, t Target, args Arguments) (bool, error)
+	String() string
+}
+
+// ConditionConfig is used to configure the conditions a target must satisfy.
+// It is passed to ParseConditions to create Condition instances.
+// Every condition that is set must be satisfied.
+type ConditionConfig struct {
+	// A file that must exist in the target.
+	// Must be relative to the target root.
+	IfFileExists string `yaml:"ifFileExists"`
+	// A file in the target that must contain text matching a regex.
+	IfFileMatches *FileMatchConfig `yaml:"ifFileMatches"`
+	// A shell command that must succeed when run in the target.
+	IfCommandSucceeds string `yaml:"ifCommandSucceeds"`
+}
+
+// FileMatchConfig configures a condition that a file contains text matching a regex.
+type FileMatchConfig struct {
+	// The path to the file. Must be relative to the target root.
+	Path string `yaml:"path"`
+	// The regex to search for in the file.
+	Regex string `yaml:"regex"`
+}
+
+// ParseConditions parses a config that describes conditions and returns the Conditions.
+func ParseConditions(cfg ConditionConfig) ([]Condition, error) {
+	var conds []Condition
+	if cfg.IfFileExists != "" {
+		conds = append(conds, fileExistsCondition{path: cfg.IfFileExists})
+	}
+	if fm := cfg.IfFileMatches; fm != nil {
+		if fm.Path == "" {
+			return nil, errors.New("missing path field for ifFileMatches condition")
+		}
+		if fm.Regex == "" {
+			return nil, errors.New("missing regex field for ifFileMatches condition")
+		}
+		c := fileMatchesCondition{path: fm.Path, regex: fm.Regex}
+		// Variables are expanded for each target so the regex can only be compiled
+		// once if it has none. Otherwise, check it is valid with the variables empty.
+		vm := text.NewVariableMapper(nil)
+		re, err := compileMultiline(string(text.ExpandVariables([]byte(fm.Regex), vm.Map)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for ifFileMatches condition: %w", err)
+		}
+		if len(vm.Missing()) == 0 {
+			c.re = re
+		}
+		conds = append(conds, c)
+	}
+	if cfg.IfCommandSucceeds != "" {
+		conds = append(conds, commandCondition{run: cfg.IfCommandSucceeds})
+	}
+	return conds, nil
+}
+
+// fileExistsCondition is satisfied if a file exists in the target.
+type fileExistsCondition struct {
+	path string
+}
+
+func (c fileExistsCondition) Match(_ context.Context, t Target, args Arguments) (bool, error) {
+	path, err := expand(c.path, args)
+	if err != nil {
+		return false, err
+	}
+	return file.Exists(filepath.Join(t.Path(), path)), nil
+}
+
+func (c fileExistsCondition) String() string {
+	return fmt.Sprintf("if file exists: %s", c.path)
+}
+
+// fileMatchesCondition is satisfied if a file in the target contains text matching a regex.
+// It is not satisfied if the file does not exist.
+type fileMatchesCondition struct {
+	path  string
+	regex string         // the regex from the config, may contain variables
+	re    *regexp.Regexp // the compiled regex; nil if it must be compiled after expanding variables
+}
+
+func (c fileMatchesCondition) Match(_ context.Context, t Target, args Arguments) (bool, error) {
+	path, err := expand(c.path, args)
+	if err != nil {
+		return false, err
+	}
+	regex := c.re
+	if regex == nil {
+		regexStr, err := expand(c.regex, args)
+		if err != nil {
+			return false, err
+		}
+		regex, err = compileMultiline(regexStr)
+		if err != nil {
+			return false, fmt.Errorf("invalid regex %s: %w", regexStr, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(t.Path(), path))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	return regex.Match(data), nil
+}
+
+func (c fileMatchesCondition) String() string {
+	return fmt.Sprintf("if file matches: %s\n  regex: %s", c.path, c.regex)
+}
+
+// compileMultiline compiles regex so that ^ and $ match at the start and end of each line.
+func compileMultiline(regex string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?m)" + regex)
+}
+
+// commandCondition is satisfied if a shell command exits successfully in the target.
+type commandCondition struct {
+	run string
+}
+
+func (c commandCondition) Match(ctx context.Context, t Target, _ Arguments) (bool, error) {
+	var errbuf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.run)
+	cmd.Stderr = &errbuf
+	cmd.Dir = t.Path()
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		// The command ran but failed, which means the condition isn't satisfied.
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to run command %s at %s: %s: %w", c.run, t.Path(), errbuf.String(), err)
+	}
+	return true, nil
+}
+
+func (c commandCondition) String() string {
+	return fmt.Sprintf("if command succeeds: %s", c.run)
+}
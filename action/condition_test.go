@@ -0,0 +1,127 @@
+package action_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  action.ConditionConfig
+		want bool
+	}{
+		{
+			name: "file exists",
+			cfg:  action.ConditionConfig{IfFileExists: "Dockerfile"},
+			want: true,
+		},
+		{
+			name: "file does not exist",
+			cfg:  action.ConditionConfig{IfFileExists: "package.json"},
+			want: false,
+		},
+		{
+			name: "file matches",
+			cfg: action.ConditionConfig{
+				IfFileMatches: &action.FileMatchConfig{Path: "Dockerfile", Regex: `^FROM node:14`},
+			},
+			want: true,
+		},
+		{
+			name: "file does not match",
+			cfg: action.ConditionConfig{
+				IfFileMatches: &action.FileMatchConfig{Path: "Dockerfile", Regex: `^FROM node:16`},
+			},
+			want: false,
+		},
+		{
+			name: "file to match does not exist",
+			cfg: action.ConditionConfig{
+				IfFileMatches: &action.FileMatchConfig{Path: "Dockerfile.dev", Regex: `node`},
+			},
+			want: false,
+		},
+		{
+			name: "file matches with variables",
+			cfg: action.ConditionConfig{
+				IfFileMatches: &action.FileMatchConfig{Path: "Dockerfile", Regex: `^WORKDIR /${REPO_NAME}$`},
+			},
+			want: true,
+		},
+		{
+			name: "command succeeds",
+			cfg:  action.ConditionConfig{IfCommandSucceeds: "grep -q node Dockerfile"},
+			want: true,
+		},
+		{
+			name: "command fails",
+			cfg:  action.ConditionConfig{IfCommandSucceeds: "test -f package.json"},
+			want: false,
+		},
+		{
+			name: "all conditions must match",
+			cfg: action.ConditionConfig{
+				IfFileExists:      "Dockerfile",
+				IfCommandSucceeds: "test -f package.json",
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			data := "FROM node:14-alpine\nWORKDIR /hype\n"
+			if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(data), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			conds, err := action.ParseConditions(tt.cfg)
+			if err != nil {
+				t.Fatalf("failed to parse conditions: %v", err)
+			}
+			args := action.Arguments{Variables: map[string]string{"REPO_NAME": "hype"}}
+			got := true
+			for _, c := range conds {
+				ok, err := c.Match(context.Background(), pathTarget(dir), args)
+				if err != nil {
+					t.Fatalf("failed to match condition: %v", err)
+				}
+				got = got && ok
+			}
+			if got != tt.want {
+				t.Errorf("got match %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConditionsError(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  action.ConditionConfig
+	}{
+		{
+			name: "file matches without regex",
+			cfg:  action.ConditionConfig{IfFileMatches: &action.FileMatchConfig{Path: "Dockerfile"}},
+		},
+		{
+			name: "invalid regex",
+			cfg:  action.ConditionConfig{IfFileMatches: &action.FileMatchConfig{Path: "Dockerfile", Regex: `^FROM node:(14`}},
+		},
+		{
+			name: "invalid regex with variables",
+			cfg:  action.ConditionConfig{IfFileMatches: &action.FileMatchConfig{Path: "Dockerfile", Regex: `^WORKDIR /${REPO_NAME}[`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := action.ParseConditions(tt.cfg); err == nil {
+				t.Error("want non-nil error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/TouchBistro/goutils/file"
+	"golang.org/x/mod/module"
+)
+
+// dependencyManifests are the files that are checked by a dependency action if no path is given.
+var dependencyManifests = []string{"package.json", "go.mod", "requirements.txt"}
+
+// dependencyAction is an action that bumps the version of a dependency
+// in the manifest file of a package manager.
+type dependencyAction struct {
+	name     string // name of the dependency
+	version  string // the version to bump to
+	path     string // path to the manifest; if empty all dependencyManifests are checked
+	required bool   // whether the dependency must be declared
+}
+
+// versionChange is an occurrence of a dependency in a manifest.
+type versionChange struct {
+	old, new string
+}
+
+// bumpFunc updates the version of the dependency name in the manifest data.
+// It returns the updated manifest and all occurrences of the dependency that were found.
+type bumpFunc func(data []byte, name, version string) ([]byte, []versionChange, error)
+
+func parseDependencyAction(cfg Config) (Action, error) {
+	if cfg.Type != "bumpDependency" {
+		return nil, fmt.Errorf("unsupported dependency action type %s", cfg.Type)
+	}
+	if cfg.Dependency == "" {
+		return nil, errors.New("missing dependency for dependency action")
+	}
+	if cfg.Version == "" {
+		return nil, errors.New("missing version for dependency action")
+	}
+	if cfg.Path != "" {
+		if _, err := dependencyBumper(cfg.Path); err != nil {
+			return nil, err
+		}
+	}
+	return dependencyAction{name: cfg.Dependency, version: cfg.Version, path: cfg.Path, required: cfg.Required}, nil
+}
+
+// dependencyBumper returns the bumpFunc for the manifest at path based on its file name.
+func dependencyBumper(path string) (bumpFunc, error) {
+	base := filepath.Base(path)
+	switch {
+	case base == "package.json":
+		return bumpPackageJSON, nil
+	case base == "go.mod":
+		return bumpGoMod, nil
+	case strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt"):
+		return bumpRequirements, nil
+	default:
+		return nil, fmt.Errorf("unsupported dependency manifest %s, must be package.json, go.mod or requirements.txt", path)
+	}
+}
+
+func (a dependencyAction) Run(_ context.Context, t Target, args Arguments) (Result, error) {
+	version, err := expand(a.version, args)
+	if err != nil {
+		return Result{}, err
+	}
+	if version == "" {
+		return Result{}, fmt.Errorf("version for dependency %s is empty", a.name)
+	}
+	paths := dependencyManifests
+	if a.path != "" {
+		paths = []string{a.path}
+	}
+
+	var files, details []string
+	var lastChange versionChange
+	var matches int
+	var changed bool
+	for _, p := range paths {
+		path := filepath.Join(t.Path(), p)
+		if a.path == "" && !file.Exists(path) {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		bump, err := dependencyBumper(p)
+		if err != nil {
+			return Result{}, err
+		}
+		output, changes, err := bump(data, a.name, version)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to bump %s in %s: %w", a.name, path, err)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		files = append(files, p)
+		matches += len(changes)
+		for _, c := range changes {
+			details = append(details, fmt.Sprintf("* `%s`: `%s` → `%s`", p, c.old, c.new))
+			lastChange = c
+		}
+		if bytes.Equal(output, data) {
+			continue
+		}
+		changed = true
+		if err := os.WriteFile(path, output, 0o644); err != nil {
+			return Result{}, fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+	}
+	if a.required && matches == 0 {
+		return Result{}, fmt.Errorf("dependency %s not found", a.name)
+	}
+
+	res := Result{Files: files, Matches: matches, Changed: changed}
+	switch {
+	case matches == 0:
+		res.Message = fmt.Sprintf("Dependency `%s` not found", a.name)
+	case matches == 1 && !changed:
+		res.Message = fmt.Sprintf("Dependency `%s` is already `%s` in `%s`", a.name, lastChange.new, files[0])
+	case !changed:
+		res.Message = fmt.Sprintf("Dependency `%s` is already `%s`", a.name, version)
+	case matches == 1:
+		res.Message = fmt.Sprintf("Bumped `%s` from `%s` to `%s` in `%s`", a.name, lastChange.old, lastChange.new, files[0])
+	default:
+		// Multiple occurrences, list each one in the detail.
+		res.Message = fmt.Sprintf("Bumped `%s` to `%s`", a.name, version)
+		res.Detail = strings.Join(details, "\n")
+	}
+	return res, nil
+}
+
+func (a dependencyAction) String() string {
+	path := a.path
+	if path == "" {
+		path = strings.Join(dependencyManifests, ", ")
+	}
+	return fmt.Sprintf("bump dependency: %q\n  version: %q\n  path: %q", a.name, a.version, path)
+}
+
+// newConstraint returns the version constraint that replaces old in order to require version.
+// If version has no operator, the operator of old is kept so that ^1.0.0 becomes ^2.0.0.
+// ops contains the characters that make up operators.
+func newConstraint(old, version, ops string) string {
+	if strings.ContainsAny(version[:1], ops) {
+		return version
+	}
+	op := old[:len(old)-len(strings.TrimLeft(old, ops))]
+	return op + version
+}
+
+// packageJSONSections are the sections of a package.json that contain dependencies.
+var packageJSONSections = []string{"dependencies", "devDependencies", "peerDependencies", "optionalDependencies"}
+
+// npmVersionRegex matches npm version ranges that can be bumped.
+// Other specifiers like tags, URLs or workspace references are left alone.
+var npmVersionRegex = regexp.MustCompile(`^[\^~<>=]*v?\d+(\.[\dxX*]+){0,2}([-+][0-9A-Za-z.-]+)?$`)
+
+func bumpPackageJSON(data []byte, name, version string) ([]byte, []versionChange, error) {
+	root, err := decodeJSONFile(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	obj, ok := root.(jsonObject)
+	if !ok {
+		return nil, nil, errors.New("package.json must contain an object")
+	}
+	var changes []versionChange
+	for _, section := range packageJSONSections {
+		i := obj.index(section)
+		if i < 0 {
+			continue
+		}
+		deps, ok := obj[i].Value.(jsonObject)
+		if !ok {
+			continue
+		}
+		j := deps.index(name)
+		if j < 0 {
+			continue
+		}
+		old, ok := deps[j].Value.(string)
+		if !ok || !npmVersionRegex.MatchString(old) {
+			continue
+		}
+		c := versionChange{old: old, new: newConstraint(old, version, "^~<>=")}
+		deps[j].Value = c.new
+		changes = append(changes, c)
+	}
+	if len(changes) == 0 {
+		return data, nil, nil
+	}
+	changed := false
+	for _, c := range changes {
+		changed = changed || c.old != c.new
+	}
+	if !changed {
+		return data, changes, nil
+	}
+	output, err := encodeJSONFile(root, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return output, changes, nil
+}
+
+func bumpGoMod(data []byte, name, version string) ([]byte, []versionChange, error) {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	var changes []versionChange
+	inRequire := false
+	for i, line := range lines {
+		code := line
+		if j := strings.Index(code, "//"); j >= 0 {
+			code = code[:j]
+		}
+		fields := strings.Fields(code)
+		switch {
+		case len(fields) == 2 && fields[0] == "require" && fields[1] == "(":
+			inRequire = true
+			continue
+		case inRequire && len(fields) == 1 && fields[0] == ")":
+			inRequire = false
+			continue
+		case !inRequire && len(fields) == 3 && fields[0] == "require":
+			fields = fields[1:]
+		case !inRequire:
+			continue
+		}
+		if len(fields) != 2 || fields[0] != name {
+			continue
+		}
+		// Only check the version once the module is found, the dependency might be for another manifest.
+		if err := module.Check(name, version); err != nil {
+			return nil, nil, err
+		}
+		c := versionChange{old: fields[1], new: version}
+		// Replace the version after the module path so the rest of the line, like comments, is kept.
+		j := strings.Index(line, name) + len(name)
+		lines[i] = line[:j] + strings.Replace(line[j:], c.old, c.new, 1)
+		changes = append(changes, c)
+	}
+	return []byte(strings.Join(lines, "")), changes, nil
+}
+
+// requirementRegex matches a requirement with a version specifier in a pip requirements file,
+// for example requests[security]>=2.28,<3 ; python_version > "3.7".
+var requirementRegex = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*((?:===|~=|==|!=|<=|>=|<|>)\s*[^\s,;#]+(?:\s*,\s*(?:===|~=|==|!=|<=|>=|<|>)\s*[^\s,;#]+)*)`)
+
+func bumpRequirements(data []byte, name, version string) ([]byte, []versionChange, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+	var changes []versionChange
+	for i, line := range lines {
+		m := requirementRegex.FindStringSubmatchIndex(line)
+		if m == nil || normalizePythonName(line[m[2]:m[3]]) != normalizePythonName(name) {
+			continue
+		}
+		old := line[m[4]:m[5]]
+		c := versionChange{old: old, new: "==" + version}
+		if !strings.Contains(old, ",") {
+			// Keep the operator if there is a single specifier.
+			c.new = newConstraint(old, version, "=~!<> ")
+		}
+		if strings.ContainsAny(version[:1], "=~!<>") {
+			c.new = version
+		}
+		lines[i] = line[:m[4]] + c.new + line[m[5]:]
+		changes = append(changes, c)
+	}
+	return []byte(strings.Join(lines, "")), changes, nil
+}
+
+var pythonNameSeparatorRegex = regexp.MustCompile(`[-_.]+`)
+
+// normalizePythonName normalizes a Python package name so that names can be compared.
+// See https://peps.python.org/pep-0503/#normalized-names.
+func normalizePythonName(name string) string {
+	return strings.ToLower(pythonNameSeparatorRegex.ReplaceAllString(name, "-"))
+}
@@ -0,0 +1,297 @@
+package action_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+const inputPackageJSON = `{
+  "name": "hype",
+  "dependencies": {
+    "express": "^4.17.1",
+    "left-pad": "github:hype/left-pad"
+  },
+  "devDependencies": {
+    "express": "~4.17.1"
+  }
+}
+`
+
+const inputGoMod = `module github.com/TouchBistro/hype
+
+go 1.18
+
+require github.com/TouchBistro/goutils v0.4.0
+
+require (
+	github.com/go-git/go-git/v5 v5.5.1
+	golang.org/x/mod v0.7.0 // indirect
+)
+`
+
+const inputRequirements = `# Web
+Flask==2.2.2
+requests[security]>=2.28.1 ; python_version > "3.7"
+django-rest-framework>=3.0,<4
+pytest
+`
+
+func TestDependencyAction(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		cfg         action.Config
+		wantMsg     string
+		wantDetail  string
+		wantMatches int
+		wantChanged bool
+		want        map[string]string
+	}{
+		{
+			name:  "package.json keeps range operators",
+			files: map[string]string{"package.json": inputPackageJSON},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "express",
+				Version:    "4.18.2",
+			},
+			wantMsg:     "Bumped `express` to `4.18.2`",
+			wantDetail:  "* `package.json`: `^4.17.1` → `^4.18.2`\n* `package.json`: `~4.17.1` → `~4.18.2`",
+			wantMatches: 2,
+			wantChanged: true,
+			want: map[string]string{"package.json": `{
+  "name": "hype",
+  "dependencies": {
+    "express": "^4.18.2",
+    "left-pad": "github:hype/left-pad"
+  },
+  "devDependencies": {
+    "express": "~4.18.2"
+  }
+}
+`},
+		},
+		{
+			name:  "package.json unchanged",
+			files: map[string]string{"package.json": inputPackageJSON},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "express",
+				Version:    "4.17.1",
+			},
+			wantMsg:     "Dependency `express` is already `4.17.1`",
+			wantMatches: 2,
+			want:        map[string]string{"package.json": inputPackageJSON},
+		},
+		{
+			name:  "package.json ignores non version specifiers",
+			files: map[string]string{"package.json": inputPackageJSON},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "left-pad",
+				Version:    "1.3.0",
+				Path:       "package.json",
+			},
+			wantMsg: "Dependency `left-pad` not found",
+			want:    map[string]string{"package.json": inputPackageJSON},
+		},
+		{
+			name:  "go.mod",
+			files: map[string]string{"go.mod": inputGoMod},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "golang.org/x/mod",
+				Version:    "0.8.0",
+			},
+			wantMsg:     "Bumped `golang.org/x/mod` from `v0.7.0` to `v0.8.0` in `go.mod`",
+			wantMatches: 1,
+			wantChanged: true,
+			want: map[string]string{"go.mod": `module github.com/TouchBistro/hype
+
+go 1.18
+
+require github.com/TouchBistro/goutils v0.4.0
+
+require (
+	github.com/go-git/go-git/v5 v5.5.1
+	golang.org/x/mod v0.8.0 // indirect
+)
+`},
+		},
+		{
+			name:  "go.mod single line require unchanged",
+			files: map[string]string{"go.mod": inputGoMod},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "github.com/TouchBistro/goutils",
+				Version:    "v0.4.0",
+			},
+			wantMsg:     "Dependency `github.com/TouchBistro/goutils` is already `v0.4.0` in `go.mod`",
+			wantMatches: 1,
+			want:        map[string]string{"go.mod": inputGoMod},
+		},
+		{
+			name: "go.mod with toolchain",
+			files: map[string]string{"go.mod": `module github.com/TouchBistro/hype
+
+go 1.22.0
+
+toolchain go1.22.1
+
+require github.com/TouchBistro/goutils v0.4.0
+`},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "github.com/TouchBistro/goutils",
+				Version:    "v0.5.0",
+			},
+			wantMsg:     "Bumped `github.com/TouchBistro/goutils` from `v0.4.0` to `v0.5.0` in `go.mod`",
+			wantMatches: 1,
+			wantChanged: true,
+			want: map[string]string{"go.mod": `module github.com/TouchBistro/hype
+
+go 1.22.0
+
+toolchain go1.22.1
+
+require github.com/TouchBistro/goutils v0.5.0
+`},
+		},
+		{
+			name:  "requirements",
+			files: map[string]string{"requirements.txt": inputRequirements},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "Requests",
+				Version:    "2.31.0",
+			},
+			wantMsg:     "Bumped `Requests` from `>=2.28.1` to `>=2.31.0` in `requirements.txt`",
+			wantMatches: 1,
+			wantChanged: true,
+			want: map[string]string{"requirements.txt": `# Web
+Flask==2.2.2
+requests[security]>=2.31.0 ; python_version > "3.7"
+django-rest-framework>=3.0,<4
+pytest
+`},
+		},
+		{
+			name:  "requirements multiple specifiers",
+			files: map[string]string{"requirements-dev.txt": inputRequirements},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "django_rest_framework",
+				Version:    "3.14.0",
+				Path:       "requirements-dev.txt",
+			},
+			wantMsg:     "Bumped `django_rest_framework` from `>=3.0,<4` to `==3.14.0` in `requirements-dev.txt`",
+			wantMatches: 1,
+			wantChanged: true,
+			want: map[string]string{"requirements-dev.txt": `# Web
+Flask==2.2.2
+requests[security]>=2.28.1 ; python_version > "3.7"
+django-rest-framework==3.14.0
+pytest
+`},
+		},
+		{
+			name:  "requirements without version",
+			files: map[string]string{"requirements.txt": inputRequirements},
+			cfg: action.Config{
+				Type:       "bumpDependency",
+				Dependency: "pytest",
+				Version:    "7.2.0",
+			},
+			wantMsg: "Dependency `pytest` not found",
+			want:    map[string]string{"requirements.txt": inputRequirements},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := t.TempDir()
+			for name, data := range tt.files {
+				if err := os.WriteFile(filepath.Join(td, name), []byte(data), 0o644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if res.Detail != tt.wantDetail {
+				t.Errorf("got detail\n\t%s\nwant\n\t%s", res.Detail, tt.wantDetail)
+			}
+			if res.Matches != tt.wantMatches {
+				t.Errorf("got %d matches, want %d", res.Matches, tt.wantMatches)
+			}
+			if res.Changed != tt.wantChanged {
+				t.Errorf("got changed %t, want %t", res.Changed, tt.wantChanged)
+			}
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(td, name))
+				if err != nil {
+					t.Fatalf("failed to read file: %v", err)
+				}
+				if got := string(data); got != want {
+					t.Errorf("got %s\n\t%s\nwant\n\t%s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDependencyActionError(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  action.Config
+		vars map[string]string
+	}{
+		{
+			name: "empty version",
+			cfg:  action.Config{Type: "bumpDependency", Dependency: "express", Version: "${VERSION}"},
+			vars: map[string]string{"VERSION": ""},
+		},
+		{
+			name: "required dependency missing",
+			cfg:  action.Config{Type: "bumpDependency", Dependency: "react", Version: "18.2.0", Required: true},
+		},
+		{
+			name: "invalid Go module version",
+			cfg:  action.Config{Type: "bumpDependency", Dependency: "golang.org/x/mod", Version: "latest"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := t.TempDir()
+			if err := os.WriteFile(filepath.Join(td, "package.json"), []byte(inputPackageJSON), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(td, "go.mod"), []byte(inputGoMod), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			_, err = a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
+			if err == nil {
+				t.Error("want non-nil error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type envActionType int
+
+const (
+	envSet envActionType = iota
+	envDelete
+)
+
+// envAction is an action that edits a variable in a dotenv file like .env.example.
+// Other lines, comments and the quoting style of existing values are preserved.
+type envAction struct {
+	typ      envActionType
+	path     string // path to the dotenv file
+	name     string // name of the variable
+	value    string // the value to set; only used by set actions
+	required bool   // whether the variable must already exist
+}
+
+func parseEnvAction(cfg Config) (Action, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("missing path for env var action")
+	}
+	if cfg.Key == "" {
+		return nil, errors.New("missing key for env var action")
+	}
+	if strings.ContainsAny(cfg.Key, " \t=#") {
+		return nil, fmt.Errorf("invalid env var name %q", cfg.Key)
+	}
+	a := envAction{path: cfg.Path, name: cfg.Key, required: cfg.Required}
+	switch cfg.Type {
+	case "setEnvVar":
+		if cfg.Value == nil {
+			return nil, errors.New("missing value for setEnvVar action")
+		}
+		value, err := scalarString(cfg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for setEnvVar action: %w", err)
+		}
+		a.typ = envSet
+		a.value = value
+	case "deleteEnvVar":
+		a.typ = envDelete
+	default:
+		return nil, fmt.Errorf("unsupported env var action type %s", cfg.Type)
+	}
+	return a, nil
+}
+
+func (a envAction) Run(_ context.Context, t Target, args Arguments) (Result, error) {
+	path := filepath.Join(t.Path(), a.path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	value, err := expand(a.value, args)
+	if err != nil {
+		return Result{}, err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	var out []string
+	var matches int
+	var changed bool
+	for _, line := range lines {
+		l, ok := parseEnvLine(line)
+		if !ok || l.name != a.name {
+			out = append(out, line)
+			continue
+		}
+		matches++
+		if a.typ == envDelete {
+			changed = true
+			continue
+		}
+		if l.value == value {
+			out = append(out, line)
+			continue
+		}
+		out = append(out, l.head+quoteEnvValue(value, l.quote)+l.tail)
+		changed = true
+	}
+	if a.required && matches == 0 {
+		return Result{}, fmt.Errorf("variable %s not found in %s", a.name, path)
+	}
+	if a.typ == envSet && matches == 0 {
+		if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+			out = append(out, "\n")
+		}
+		out = append(out, a.name+"="+quoteEnvValue(value, 0)+"\n")
+		changed = true
+	}
+
+	var msg string
+	switch {
+	case a.typ == envSet && matches > 0:
+		msg = fmt.Sprintf("Set `%s` to `%s` in `%s`", a.name, value, a.path)
+	case a.typ == envSet:
+		msg = fmt.Sprintf("Added `%s` with value `%s` to `%s`", a.name, value, a.path)
+	case matches > 0:
+		msg = fmt.Sprintf("Deleted `%s` from `%s`", a.name, a.path)
+	default:
+		msg = fmt.Sprintf("Variable `%s` not found in `%s`", a.name, a.path)
+	}
+	if changed {
+		if err := os.WriteFile(path, []byte(strings.Join(out, "")), 0o644); err != nil {
+			return Result{}, fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+	}
+	return Result{Message: msg, Files: []string{a.path}, Matches: matches, Changed: changed}, nil
+}
+
+func (a envAction) String() string {
+	switch a.typ {
+	case envSet:
+		return fmt.Sprintf("set env var: %q\n  value: %q\n  path: %q", a.name, a.value, a.path)
+	case envDelete:
+		return fmt.Sprintf("delete env var: %q\n  path: %q", a.name, a.path)
+	default:
+		panic("impossible: invalid type")
+	}
+}
+
+// envLine is a variable assignment in a dotenv file.
+// A line can be reconstructed with head + the quoted value + tail.
+type envLine struct {
+	head  string // everything before the value, including any export keyword and the =
+	name  string // name of the variable
+	quote byte   // the quote character around the value or 0 if it is unquoted
+	value string // the unquoted value
+	tail  string // everything after the value, like an inline comment and the newline
+}
+
+// parseEnvLine parses a line of a dotenv file. It returns false if the line
+// is not a variable assignment, for example if it is blank or a comment.
+func parseEnvLine(line string) (envLine, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || trimmed[0] == '#' {
+		return envLine{}, false
+	}
+	i := strings.IndexByte(line, '=')
+	if i < 0 {
+		return envLine{}, false
+	}
+	name := strings.TrimSpace(line[:i])
+	name = strings.TrimSpace(strings.TrimPrefix(name, "export "))
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return envLine{}, false
+	}
+
+	rest := line[i+1:]
+	start := len(rest) - len(strings.TrimLeft(rest, " \t"))
+	l := envLine{head: line[:i+1] + rest[:start], name: name}
+	rest = rest[start:]
+	if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+		q := rest[0]
+		for j := 1; j < len(rest); j++ {
+			if rest[j] == '\\' && q == '"' {
+				j++
+				continue
+			}
+			if rest[j] == q {
+				l.quote = q
+				l.value = rest[1:j]
+				if q == '"' {
+					l.value = unescapeEnvValue(l.value)
+				}
+				l.tail = rest[j+1:]
+				return l, true
+			}
+		}
+		// No closing quote, treat it as an unquoted value.
+	}
+	// An unquoted value ends at an inline comment or the end of the line.
+	end := strings.Index(rest, " #")
+	if end < 0 {
+		end = len(rest)
+	}
+	l.value = strings.TrimRight(rest[:end], " \t\r\n")
+	l.tail = rest[len(l.value):]
+	return l, true
+}
+
+// quoteEnvValue returns value quoted so that it can be written to a dotenv file.
+// It uses quote if possible, otherwise the value is only quoted if needed.
+func quoteEnvValue(value string, quote byte) string {
+	switch {
+	case quote == '\'' && !strings.ContainsAny(value, "'\n"):
+		return "'" + value + "'"
+	case quote == '"' || strings.ContainsAny(value, " \t\n\r#\"'\\"):
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+		return `"` + r.Replace(value) + `"`
+	default:
+		return value
+	}
+}
+
+func unescapeEnvValue(value string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\r`, "\r")
+	return r.Replace(value)
+}
+
+// scalarString returns a string representation of a scalar value from the config.
+func scalarString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("must be a string, number or boolean, got %T", value)
+	}
+}
@@ -0,0 +1,147 @@
+package action_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+const inputEnv = `# Database
+DB_USER=SA
+DB_PASSWORD="hype pass" # local only
+export DB_NAME='hype'
+
+PORT=8080
+`
+
+func TestEnvAction(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		cfg         action.Config
+		vars        map[string]string
+		wantMsg     string
+		wantMatches int
+		out         string
+	}{
+		{
+			name:        "set unquoted",
+			in:          inputEnv,
+			cfg:         action.Config{Type: "setEnvVar", Key: "DB_USER", Value: "core", Path: "unquoted.env"},
+			wantMsg:     "Set `DB_USER` to `core` in `unquoted.env`",
+			wantMatches: 1,
+			out: `# Database
+DB_USER=core
+DB_PASSWORD="hype pass" # local only
+export DB_NAME='hype'
+
+PORT=8080
+`,
+		},
+		{
+			name:        "set keeps quotes and comment",
+			in:          inputEnv,
+			cfg:         action.Config{Type: "setEnvVar", Key: "DB_PASSWORD", Value: `${PASS}`, Path: "quoted.env"},
+			vars:        map[string]string{"PASS": `woke "pass"`},
+			wantMsg:     "Set `DB_PASSWORD` to `woke \"pass\"` in `quoted.env`",
+			wantMatches: 1,
+			out: `# Database
+DB_USER=SA
+DB_PASSWORD="woke \"pass\"" # local only
+export DB_NAME='hype'
+
+PORT=8080
+`,
+		},
+		{
+			name:        "set exported",
+			in:          inputEnv,
+			cfg:         action.Config{Type: "setEnvVar", Key: "DB_NAME", Value: "woke", Path: "exported.env"},
+			wantMsg:     "Set `DB_NAME` to `woke` in `exported.env`",
+			wantMatches: 1,
+			out: `# Database
+DB_USER=SA
+DB_PASSWORD="hype pass" # local only
+export DB_NAME='woke'
+
+PORT=8080
+`,
+		},
+		{
+			name:        "set unchanged",
+			in:          inputEnv,
+			cfg:         action.Config{Type: "setEnvVar", Key: "PORT", Value: 8080, Path: "unchanged.env"},
+			wantMsg:     "Set `PORT` to `8080` in `unchanged.env`",
+			wantMatches: 1,
+			out:         inputEnv,
+		},
+		{
+			name:        "add missing",
+			in:          "DB_USER=SA",
+			cfg:         action.Config{Type: "setEnvVar", Key: "DB_HOST", Value: "local host", Path: "add.env"},
+			wantMsg:     "Added `DB_HOST` with value `local host` to `add.env`",
+			wantMatches: 0,
+			out:         "DB_USER=SA\nDB_HOST=\"local host\"\n",
+		},
+		{
+			name:        "delete",
+			in:          inputEnv,
+			cfg:         action.Config{Type: "deleteEnvVar", Key: "DB_PASSWORD", Path: "delete.env"},
+			wantMsg:     "Deleted `DB_PASSWORD` from `delete.env`",
+			wantMatches: 1,
+			out: `# Database
+DB_USER=SA
+export DB_NAME='hype'
+
+PORT=8080
+`,
+		},
+		{
+			name:        "delete missing",
+			in:          inputEnv,
+			cfg:         action.Config{Type: "deleteEnvVar", Key: "DB_HOST", Path: "delete_missing.env"},
+			wantMsg:     "Variable `DB_HOST` not found in `delete_missing.env`",
+			wantMatches: 0,
+			out:         inputEnv,
+		},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(td, tt.cfg.Path)
+			if err := os.WriteFile(path, []byte(tt.in), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if res.Matches != tt.wantMatches {
+				t.Errorf("got %d matches, want %d", res.Matches, tt.wantMatches)
+			}
+			if wantChanged := tt.in != tt.out; res.Changed != wantChanged {
+				t.Errorf("got changed %t, want %t", res.Changed, wantChanged)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if got := string(data); got != tt.out {
+				t.Errorf("got file\n\t%s\nwant\n\t%s", got, tt.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+type goModActionType int
+
+const (
+	goModRequire goModActionType = iota
+	goModReplace
+	goModDropReplace
+	goModSetGoVersion
+)
+
+// goModAction is an action that edits a go.mod file.
+// The file is edited with modfile so the formatting and comments are kept.
+type goModAction struct {
+	typ         goModActionType
+	path        string // path to the go.mod file
+	module      string // the module to require or the module being replaced; may include @version for replace actions
+	version     string // the version to require or the Go version
+	replacement string // the replacement module for replace actions, either path@version or a local directory
+}
+
+func parseGoModAction(cfg Config) (Action, error) {
+	a := goModAction{path: cfg.Path, module: cfg.Module, version: cfg.Version, replacement: cfg.Replacement}
+	if a.path == "" {
+		a.path = "go.mod"
+	}
+	switch cfg.Type {
+	case "goModRequire":
+		a.typ = goModRequire
+		if a.module == "" {
+			return nil, errors.New("missing module for goModRequire action")
+		}
+		if a.version == "" {
+			return nil, errors.New("missing version for goModRequire action")
+		}
+	case "goModReplace":
+		a.typ = goModReplace
+		if a.module == "" {
+			return nil, errors.New("missing module for goModReplace action")
+		}
+		if a.replacement == "" {
+			return nil, errors.New("missing replacement for goModReplace action")
+		}
+	case "goModDropReplace":
+		a.typ = goModDropReplace
+		if a.module == "" {
+			return nil, errors.New("missing module for goModDropReplace action")
+		}
+	case "goModSetGoVersion":
+		a.typ = goModSetGoVersion
+		if a.version == "" {
+			return nil, errors.New("missing version for goModSetGoVersion action")
+		}
+		if !modfile.GoVersionRE.MatchString(a.version) {
+			return nil, fmt.Errorf("invalid Go version %q", a.version)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported go.mod action type %s", cfg.Type)
+	}
+	return a, nil
+}
+
+func (a goModAction) Run(_ context.Context, t Target, args Arguments) (Result, error) {
+	path := filepath.Join(t.Path(), a.path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	f, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var res Result
+	switch a.typ {
+	case goModRequire:
+		res, err = a.require(f, args)
+	case goModReplace:
+		res, err = a.replace(f, args)
+	case goModDropReplace:
+		res, err = a.dropReplace(f, args)
+	case goModSetGoVersion:
+		res, err = a.setGoVersion(f)
+	default:
+		panic("impossible: invalid type")
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	res.Files = []string{a.path}
+	if !res.Changed {
+		return res, nil
+	}
+
+	f.Cleanup()
+	output, err := f.Format()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to format %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, output, 0o644); err != nil {
+		return Result{}, fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return res, nil
+}
+
+func (a goModAction) require(f *modfile.File, args Arguments) (Result, error) {
+	mod, err := expand(a.module, args)
+	if err != nil {
+		return Result{}, err
+	}
+	version, err := expand(a.version, args)
+	if err != nil {
+		return Result{}, err
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if err := module.Check(mod, version); err != nil {
+		return Result{}, err
+	}
+	var old string
+	for _, r := range f.Require {
+		if r.Mod.Path == mod {
+			old = r.Mod.Version
+		}
+	}
+	switch {
+	case old == version:
+		return Result{Message: fmt.Sprintf("`%s` is already required at `%s` in `%s`", mod, version, a.path), Matches: 1}, nil
+	case old != "":
+		if err := f.AddRequire(mod, version); err != nil {
+			return Result{}, err
+		}
+		msg := fmt.Sprintf("Updated `%s` from `%s` to `%s` in `%s`", mod, old, version, a.path)
+		return Result{Message: msg, Matches: 1, Changed: true}, nil
+	default:
+		if err := f.AddRequire(mod, version); err != nil {
+			return Result{}, err
+		}
+		return Result{Message: fmt.Sprintf("Required `%s` at `%s` in `%s`", mod, version, a.path), Changed: true}, nil
+	}
+}
+
+func (a goModAction) replace(f *modfile.File, args Arguments) (Result, error) {
+	oldPath, oldVersion, err := parseModule(a.module, args)
+	if err != nil {
+		return Result{}, err
+	}
+	newPath, newVersion, err := parseModule(a.replacement, args)
+	if err != nil {
+		return Result{}, err
+	}
+	if newVersion == "" && !isLocalPath(newPath) {
+		return Result{}, fmt.Errorf("replacement %s must have a version or be a local directory", newPath)
+	}
+	var existed bool
+	for _, r := range f.Replace {
+		if r.Old.Path != oldPath || r.Old.Version != oldVersion {
+			continue
+		}
+		existed = true
+		if r.New.Path == newPath && r.New.Version == newVersion {
+			msg := fmt.Sprintf("`%s` is already replaced with `%s` in `%s`", a.module, a.replacement, a.path)
+			return Result{Message: msg, Matches: 1}, nil
+		}
+	}
+	if err := f.AddReplace(oldPath, oldVersion, newPath, newVersion); err != nil {
+		return Result{}, err
+	}
+	res := Result{Message: fmt.Sprintf("Replaced `%s` with `%s` in `%s`", a.module, a.replacement, a.path), Changed: true}
+	if existed {
+		res.Matches = 1
+	}
+	return res, nil
+}
+
+func (a goModAction) dropReplace(f *modfile.File, args Arguments) (Result, error) {
+	oldPath, oldVersion, err := parseModule(a.module, args)
+	if err != nil {
+		return Result{}, err
+	}
+	var found bool
+	for _, r := range f.Replace {
+		if r.Old.Path == oldPath && r.Old.Version == oldVersion {
+			found = true
+		}
+	}
+	if !found {
+		return Result{Message: fmt.Sprintf("No replace for `%s` found in `%s`", a.module, a.path)}, nil
+	}
+	if err := f.DropReplace(oldPath, oldVersion); err != nil {
+		return Result{}, err
+	}
+	return Result{Message: fmt.Sprintf("Dropped replace for `%s` from `%s`", a.module, a.path), Matches: 1, Changed: true}, nil
+}
+
+func (a goModAction) setGoVersion(f *modfile.File) (Result, error) {
+	if f.Go != nil && f.Go.Version == a.version {
+		return Result{Message: fmt.Sprintf("Go version is already `%s` in `%s`", a.version, a.path), Matches: 1}, nil
+	}
+	res := Result{Message: fmt.Sprintf("Set Go version to `%s` in `%s`", a.version, a.path), Changed: true}
+	if f.Go != nil {
+		res.Message = fmt.Sprintf("Set Go version from `%s` to `%s` in `%s`", f.Go.Version, a.version, a.path)
+		res.Matches = 1
+	}
+	if err := f.AddGoStmt(a.version); err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+// parseModule expands variables in s and splits it into a module path and an optional version.
+func parseModule(s string, args Arguments) (path, version string, err error) {
+	s, err = expand(s, args)
+	if err != nil {
+		return "", "", err
+	}
+	path, version, _ = strings.Cut(s, "@")
+	return path, version, nil
+}
+
+func (a goModAction) String() string {
+	switch a.typ {
+	case goModRequire:
+		return fmt.Sprintf("go.mod require: %q\n  version: %q\n  path: %q", a.module, a.version, a.path)
+	case goModReplace:
+		return fmt.Sprintf("go.mod replace: %q\n  with: %q\n  path: %q", a.module, a.replacement, a.path)
+	case goModDropReplace:
+		return fmt.Sprintf("go.mod drop replace: %q\n  path: %q", a.module, a.path)
+	case goModSetGoVersion:
+		return fmt.Sprintf("go.mod set go version: %q\n  path: %q", a.version, a.path)
+	default:
+		panic("impossible: invalid type")
+	}
+}
+
+// isLocalPath reports whether path is a directory path rather than a module path.
+// This matches the rules used by the go command for replace directives.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || path == "." || path == ".." || filepath.IsAbs(path)
+}
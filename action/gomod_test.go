@@ -0,0 +1,316 @@
+package action_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+const inputGoModReplace = `module github.com/TouchBistro/hype
+
+go 1.18
+
+require (
+	github.com/TouchBistro/goutils v0.4.0
+	github.com/go-git/go-git/v5 v5.5.1 // pinned for hype
+)
+
+replace github.com/go-git/go-git/v5 => github.com/TouchBistro/go-git/v5 v5.5.1-hype
+`
+
+const inputGoModToolchain = `module github.com/TouchBistro/hype
+
+go 1.22.0
+
+toolchain go1.22.1
+
+require github.com/TouchBistro/goutils v0.4.0
+`
+
+func TestGoModAction(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string // defaults to inputGoModReplace
+		cfg         action.Config
+		vars        map[string]string
+		wantMsg     string
+		wantMatches int
+		out         string
+	}{
+		{
+			name: "require existing",
+			cfg: action.Config{
+				Type:    "goModRequire",
+				Module:  "github.com/TouchBistro/goutils",
+				Version: "${VERSION}",
+			},
+			vars:        map[string]string{"VERSION": "0.5.0"},
+			wantMsg:     "Updated `github.com/TouchBistro/goutils` from `v0.4.0` to `v0.5.0` in `go.mod`",
+			wantMatches: 1,
+			out: `module github.com/TouchBistro/hype
+
+go 1.18
+
+require (
+	github.com/TouchBistro/goutils v0.5.0
+	github.com/go-git/go-git/v5 v5.5.1 // pinned for hype
+)
+
+replace github.com/go-git/go-git/v5 => github.com/TouchBistro/go-git/v5 v5.5.1-hype
+`,
+		},
+		{
+			name: "require new",
+			cfg: action.Config{
+				Type:    "goModRequire",
+				Module:  "gopkg.in/yaml.v3",
+				Version: "v3.0.1",
+			},
+			wantMsg:     "Required `gopkg.in/yaml.v3` at `v3.0.1` in `go.mod`",
+			wantMatches: 0,
+			out: `module github.com/TouchBistro/hype
+
+go 1.18
+
+require (
+	github.com/TouchBistro/goutils v0.4.0
+	github.com/go-git/go-git/v5 v5.5.1 // pinned for hype
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/go-git/go-git/v5 => github.com/TouchBistro/go-git/v5 v5.5.1-hype
+`,
+		},
+		{
+			name: "require unchanged",
+			cfg: action.Config{
+				Type:    "goModRequire",
+				Module:  "github.com/go-git/go-git/v5",
+				Version: "v5.5.1",
+			},
+			wantMsg:     "`github.com/go-git/go-git/v5` is already required at `v5.5.1` in `go.mod`",
+			wantMatches: 1,
+			out:         inputGoModReplace,
+		},
+		{
+			name: "replace with local directory",
+			cfg: action.Config{
+				Type:        "goModReplace",
+				Module:      "github.com/TouchBistro/goutils",
+				Replacement: "../goutils",
+			},
+			wantMsg:     "Replaced `github.com/TouchBistro/goutils` with `../goutils` in `go.mod`",
+			wantMatches: 0,
+			out: `module github.com/TouchBistro/hype
+
+go 1.18
+
+require (
+	github.com/TouchBistro/goutils v0.4.0
+	github.com/go-git/go-git/v5 v5.5.1 // pinned for hype
+)
+
+replace github.com/go-git/go-git/v5 => github.com/TouchBistro/go-git/v5 v5.5.1-hype
+
+replace github.com/TouchBistro/goutils => ../goutils
+`,
+		},
+		{
+			name: "drop replace",
+			cfg: action.Config{
+				Type:   "goModDropReplace",
+				Module: "github.com/go-git/go-git/v5",
+			},
+			wantMsg:     "Dropped replace for `github.com/go-git/go-git/v5` from `go.mod`",
+			wantMatches: 1,
+			out: `module github.com/TouchBistro/hype
+
+go 1.18
+
+require (
+	github.com/TouchBistro/goutils v0.4.0
+	github.com/go-git/go-git/v5 v5.5.1 // pinned for hype
+)
+`,
+		},
+		{
+			name: "drop missing replace",
+			cfg: action.Config{
+				Type:   "goModDropReplace",
+				Module: "github.com/TouchBistro/goutils",
+			},
+			wantMsg:     "No replace for `github.com/TouchBistro/goutils` found in `go.mod`",
+			wantMatches: 0,
+			out:         inputGoModReplace,
+		},
+		{
+			name: "set go version",
+			cfg: action.Config{
+				Type:    "goModSetGoVersion",
+				Version: "1.20",
+			},
+			wantMsg:     "Set Go version from `1.18` to `1.20` in `go.mod`",
+			wantMatches: 1,
+			out: `module github.com/TouchBistro/hype
+
+go 1.20
+
+require (
+	github.com/TouchBistro/goutils v0.4.0
+	github.com/go-git/go-git/v5 v5.5.1 // pinned for hype
+)
+
+replace github.com/go-git/go-git/v5 => github.com/TouchBistro/go-git/v5 v5.5.1-hype
+`,
+		},
+		{
+			name: "set patch go version",
+			cfg: action.Config{
+				Type:    "goModSetGoVersion",
+				Version: "1.22.0",
+			},
+			wantMsg:     "Set Go version from `1.18` to `1.22.0` in `go.mod`",
+			wantMatches: 1,
+			out: `module github.com/TouchBistro/hype
+
+go 1.22.0
+
+require (
+	github.com/TouchBistro/goutils v0.4.0
+	github.com/go-git/go-git/v5 v5.5.1 // pinned for hype
+)
+
+replace github.com/go-git/go-git/v5 => github.com/TouchBistro/go-git/v5 v5.5.1-hype
+`,
+		},
+		{
+			name: "require with toolchain",
+			in:   inputGoModToolchain,
+			cfg: action.Config{
+				Type:    "goModRequire",
+				Module:  "github.com/TouchBistro/goutils",
+				Version: "v0.5.0",
+			},
+			wantMsg:     "Updated `github.com/TouchBistro/goutils` from `v0.4.0` to `v0.5.0` in `go.mod`",
+			wantMatches: 1,
+			out: `module github.com/TouchBistro/hype
+
+go 1.22.0
+
+toolchain go1.22.1
+
+require github.com/TouchBistro/goutils v0.5.0
+`,
+		},
+		{
+			name: "set go version with toolchain",
+			in:   inputGoModToolchain,
+			cfg: action.Config{
+				Type:    "goModSetGoVersion",
+				Version: "1.23.0",
+			},
+			wantMsg:     "Set Go version from `1.22.0` to `1.23.0` in `go.mod`",
+			wantMatches: 1,
+			out: `module github.com/TouchBistro/hype
+
+go 1.23.0
+
+toolchain go1.22.1
+
+require github.com/TouchBistro/goutils v0.4.0
+`,
+		},
+		{
+			name: "drop missing replace with toolchain",
+			in:   inputGoModToolchain,
+			cfg: action.Config{
+				Type:   "goModDropReplace",
+				Module: "github.com/TouchBistro/goutils",
+			},
+			wantMsg:     "No replace for `github.com/TouchBistro/goutils` found in `go.mod`",
+			wantMatches: 0,
+			out:         inputGoModToolchain,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.in
+			if in == "" {
+				in = inputGoModReplace
+			}
+			td := t.TempDir()
+			path := filepath.Join(td, "go.mod")
+			if err := os.WriteFile(path, []byte(in), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if res.Matches != tt.wantMatches {
+				t.Errorf("got %d matches, want %d", res.Matches, tt.wantMatches)
+			}
+			if wantChanged := tt.out != in; res.Changed != wantChanged {
+				t.Errorf("got changed %t, want %t", res.Changed, wantChanged)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if got := string(data); got != tt.out {
+				t.Errorf("got file\n\t%s\nwant\n\t%s", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestGoModActionError(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  action.Config
+	}{
+		{
+			name: "invalid version",
+			cfg:  action.Config{Type: "goModRequire", Module: "github.com/TouchBistro/goutils", Version: "latest"},
+		},
+		{
+			name: "wrong major version",
+			cfg:  action.Config{Type: "goModRequire", Module: "github.com/go-git/go-git/v5", Version: "v6.0.0"},
+		},
+		{
+			name: "replacement without version",
+			cfg:  action.Config{Type: "goModReplace", Module: "github.com/TouchBistro/goutils", Replacement: "github.com/hype/goutils"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := t.TempDir()
+			if err := os.WriteFile(filepath.Join(td, "go.mod"), []byte(inputGoModReplace), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			_, err = a.Run(context.Background(), pathTarget(td), action.Arguments{})
+			if err == nil {
+				t.Error("want non-nil error", err)
+			}
+		})
+	}
+}
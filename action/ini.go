@@ -0,0 +1,526 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// iniAction is an action that sets a key within a section of an INI or TOML file.
+// The file is edited line by line so that comments and formatting are preserved.
+type iniAction struct {
+	toml     bool   // whether the file is TOML; values are encoded as TOML if set
+	path     string // path to the file
+	section  string // the section containing the key; empty means before the first section
+	key      string // the key to set
+	value    any    // the value to set
+	required bool   // whether the key must already exist
+}
+
+func parseINIAction(cfg Config) (Action, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("missing path for INI action")
+	}
+	if cfg.Key == "" {
+		return nil, errors.New("missing key for INI action")
+	}
+	if cfg.Value == nil {
+		return nil, fmt.Errorf("missing value for %s action", cfg.Type)
+	}
+	a := iniAction{path: cfg.Path, section: cfg.Section, key: cfg.Key, value: cfg.Value, required: cfg.Required}
+	switch cfg.Type {
+	case "setTOML":
+		a.toml = true
+	case "setINI":
+		if _, err := scalarString(cfg.Value); err != nil {
+			return nil, fmt.Errorf("invalid value for setINI action: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported INI action type %s", cfg.Type)
+	}
+	return a, nil
+}
+
+func (a iniAction) Run(_ context.Context, t Target, args Arguments) (Result, error) {
+	path := filepath.Join(t.Path(), a.path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	value, err := a.encodeValue(args)
+	if err != nil {
+		return Result{}, err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	// Find the lines belonging to the section. Keys before the first section are in the root section.
+	start, end := -1, len(lines)
+	if a.section == "" {
+		start = 0
+	}
+	sep := " = "
+	var sawKey bool
+	var cont tomlContinuation
+	for i, line := range lines {
+		if cont.active() {
+			// The line belongs to a multi-line value so it can't be a key or a section.
+			cont.scan(line)
+			continue
+		}
+		if name, ok := iniSection(line); ok {
+			if start >= 0 {
+				end = i
+				break
+			}
+			if name == a.section {
+				start = i + 1
+			}
+			continue
+		}
+		l, ok := a.parseLine(line)
+		if !ok {
+			continue
+		}
+		if a.toml {
+			cont.scan(l.value)
+		}
+		if !sawKey {
+			// Use the same separator as the first key so new keys are formatted consistently.
+			sep = l.sep
+			sawKey = true
+		}
+		if start < 0 || l.key != a.key {
+			continue
+		}
+
+		// Found the key.
+		if a.equalValue(l.value, value) {
+			msg := fmt.Sprintf("`%s` is already `%s` in `%s`", a.qualifiedKey(), value, a.path)
+			return Result{Message: msg, Files: []string{a.path}, Matches: 1}, nil
+		}
+		if l.multiline {
+			return Result{}, fmt.Errorf("cannot set key %s in %s, multi-line values are not supported", a.qualifiedKey(), path)
+		}
+		lines[i] = l.head + value + l.tail
+		if err := a.write(path, lines); err != nil {
+			return Result{}, err
+		}
+		msg := fmt.Sprintf("Set `%s` to `%s` in `%s`", a.qualifiedKey(), value, a.path)
+		return Result{Message: msg, Files: []string{a.path}, Matches: 1, Changed: true}, nil
+	}
+
+	if a.required {
+		return Result{}, fmt.Errorf("key %s not found in %s", a.qualifiedKey(), path)
+	}
+
+	// The key was not found, add it to the end of the section or create the section if it is missing.
+	newLine := a.key + sep + value + "\n"
+	if start < 0 {
+		if len(lines) > 0 {
+			if !strings.HasSuffix(lines[len(lines)-1], "\n") {
+				lines[len(lines)-1] += "\n"
+			}
+			lines = append(lines, "\n")
+		}
+		lines = append(lines, "["+a.section+"]\n", newLine)
+	} else {
+		// Insert after the last non-blank line so blank lines between sections are kept.
+		i := end
+		for i > start && strings.TrimSpace(lines[i-1]) == "" {
+			i--
+		}
+		if i > 0 && !strings.HasSuffix(lines[i-1], "\n") {
+			lines[i-1] += "\n"
+		}
+		lines = append(lines[:i], append([]string{newLine}, lines[i:]...)...)
+	}
+	if err := a.write(path, lines); err != nil {
+		return Result{}, err
+	}
+	msg := fmt.Sprintf("Added `%s` with value `%s` to `%s`", a.qualifiedKey(), value, a.path)
+	return Result{Message: msg, Files: []string{a.path}, Changed: true}, nil
+}
+
+func (a iniAction) String() string {
+	kind := "INI"
+	if a.toml {
+		kind = "TOML"
+	}
+	return fmt.Sprintf("set %s: %q\n  value: %s\n  path: %q", kind, a.qualifiedKey(), formatValue(a.value), a.path)
+}
+
+// qualifiedKey returns the key prefixed with its section.
+func (a iniAction) qualifiedKey() string {
+	if a.section == "" {
+		return a.key
+	}
+	return a.section + "." + a.key
+}
+
+// encodeValue returns the value as it should be written to the file.
+func (a iniAction) encodeValue(args Arguments) (string, error) {
+	value, err := expandValue(a.value, args)
+	if err != nil {
+		return "", err
+	}
+	if !a.toml {
+		return scalarString(value)
+	}
+	return encodeTOMLValue(value)
+}
+
+// equalValue reports whether raw, the value as written in the file, is equal to value.
+// TOML values are compared after decoding them, so for example '1.0' and "1.0" are equal.
+func (a iniAction) equalValue(raw, value string) bool {
+	if raw == value {
+		return true
+	}
+	if !a.toml {
+		return false
+	}
+	v, err := decodeTOMLValue(raw)
+	if err != nil {
+		return false
+	}
+	encoded, err := encodeTOMLValue(v)
+	return err == nil && encoded == value
+}
+
+func (a iniAction) write(path string, lines []string) error {
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return nil
+}
+
+// iniLine is a key value pair in an INI or TOML file.
+// A line can be reconstructed with head + value + tail.
+type iniLine struct {
+	head      string // everything before the value, including the key and separator
+	key       string
+	sep       string // the separator between the key and value including whitespace
+	value     string // the raw value as written in the file
+	tail      string // everything after the value, like an inline comment and the newline
+	multiline bool   // whether the value continues on the following lines
+}
+
+// parseLine parses a line containing a key value pair. It returns false
+// if the line is something else, for example a comment or a section.
+func (a iniAction) parseLine(line string) (iniLine, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' || trimmed[0] == '[' {
+		return iniLine{}, false
+	}
+	i := strings.IndexByte(line, '=')
+	if i < 0 {
+		return iniLine{}, false
+	}
+	rest := line[i+1:]
+	start := len(rest) - len(strings.TrimLeft(rest, " \t"))
+	l := iniLine{
+		head: line[:i+1] + rest[:start],
+		key:  strings.TrimSpace(line[:i]),
+		sep:  line[len(strings.TrimRight(line[:i], " \t")):i+1] + rest[:start],
+	}
+	if a.toml {
+		l.key = strings.Trim(l.key, `"'`)
+	}
+	rest = rest[start:]
+	end := len(rest)
+	if a.toml {
+		// TOML allows comments after values, find where the value ends by skipping over strings.
+		var quote byte
+		for j := 0; j < len(rest); j++ {
+			c := rest[j]
+			switch {
+			case quote != 0 && c == '\\' && quote == '"':
+				j++
+			case quote != 0 && c == quote:
+				quote = 0
+			case quote == 0 && (c == '"' || c == '\''):
+				quote = c
+			case quote == 0 && c == '#':
+				end = j
+			}
+			if end != len(rest) {
+				break
+			}
+		}
+	}
+	l.value = strings.TrimRight(rest[:end], " \t\r\n")
+	l.tail = rest[len(l.value):]
+	if a.toml {
+		var c tomlContinuation
+		c.scan(l.value)
+		l.multiline = c.active()
+	}
+	return l, true
+}
+
+// tomlContinuation tracks whether a TOML value continues on the following lines.
+type tomlContinuation struct {
+	quote string // the delimiter of the string that is open, if any
+	depth int    // the number of open arrays and inline tables
+}
+
+// active reports whether the next line is part of a multi-line value.
+func (c tomlContinuation) active() bool {
+	return c.quote != "" || c.depth > 0
+}
+
+// scan updates the state with the contents of s.
+func (c *tomlContinuation) scan(s string) {
+	for i := 0; i < len(s); i++ {
+		if c.quote != "" {
+			switch {
+			case strings.HasPrefix(s[i:], c.quote):
+				i += len(c.quote) - 1
+				c.quote = ""
+			case s[i] == '\\' && c.quote[0] == '"':
+				i++
+			}
+			continue
+		}
+		switch s[i] {
+		case '"', '\'':
+			c.quote = s[i : i+1]
+			if strings.HasPrefix(s[i:], strings.Repeat(c.quote, 3)) {
+				c.quote = s[i : i+3]
+				i += 2
+			}
+		case '[', '{':
+			c.depth++
+		case ']', '}':
+			c.depth--
+		case '#':
+			// The rest of the line is a comment.
+			return
+		}
+	}
+	// Only multi-line strings can continue on the next line.
+	if len(c.quote) == 1 {
+		c.quote = ""
+	}
+}
+
+// iniSection returns the name of the section if line is a section header.
+// For TOML arrays of tables the whole header is returned so that they never match
+// a section name since they can appear multiple times.
+func iniSection(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "[[") {
+		return trimmed, true
+	}
+	if !strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+	end := strings.LastIndexByte(trimmed, ']')
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[1:end]), true
+}
+
+// encodeTOMLValue encodes a value from the config as a TOML value.
+func encodeTOMLValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+		return `"` + r.Replace(v) + `"`, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			// Make sure it is still a float when read back.
+			s += ".0"
+		}
+		return s, nil
+	case []any:
+		items := make([]string, len(v))
+		for i, e := range v {
+			s, err := encodeTOMLValue(e)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			s, err := encodeTOMLValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			items[i] = k + " = " + s
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	default:
+		return "", fmt.Errorf("cannot encode value of type %T as TOML", value)
+	}
+}
+
+// decodeTOMLValue decodes a TOML value that is written on a single line.
+// Only the types supported by encodeTOMLValue can be decoded, values like dates are an error.
+func decodeTOMLValue(s string) (any, error) {
+	p := tomlParser{s: s}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.i < len(s) {
+		return nil, fmt.Errorf("unexpected %q after TOML value", s[p.i:])
+	}
+	return v, nil
+}
+
+// tomlParser parses a TOML value from s.
+type tomlParser struct {
+	s string
+	i int // the offset of the next byte to parse
+}
+
+func (p *tomlParser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func (p *tomlParser) value() (any, error) {
+	p.skipSpace()
+	if p.i == len(p.s) {
+		return nil, errors.New("missing TOML value")
+	}
+	switch p.s[p.i] {
+	case '"', '\'':
+		return p.str()
+	case '[':
+		p.i++
+		arr := []any{}
+		for {
+			if p.skipSpace(); p.i < len(p.s) && p.s[p.i] == ']' {
+				p.i++
+				return arr, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+			if err := p.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		p.i++
+		table := map[string]any{}
+		for {
+			if p.skipSpace(); p.i < len(p.s) && p.s[p.i] == '}' {
+				p.i++
+				return table, nil
+			}
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			if p.skipSpace(); p.i == len(p.s) || p.s[p.i] != '=' {
+				return nil, fmt.Errorf("missing = after TOML key %s", key)
+			}
+			p.i++
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			table[key] = v
+			if err := p.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	start := p.i
+	for p.i < len(p.s) && !strings.ContainsRune(" \t,]}", rune(p.s[p.i])) {
+		p.i++
+	}
+	token := p.s[start:p.i]
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	// Base 0 handles the 0x, 0o and 0b prefixes and underscores like TOML does.
+	if n, err := strconv.ParseInt(token, 0, 64); err == nil {
+		return int(n), nil
+	}
+	if f, err := strconv.ParseFloat(strings.ReplaceAll(token, "_", ""), 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported TOML value %q", token)
+}
+
+// separator consumes the comma after an item in an array or inline table.
+// The closing delimiter is left for the caller.
+func (p *tomlParser) separator(closing byte) error {
+	p.skipSpace()
+	switch {
+	case p.i < len(p.s) && p.s[p.i] == ',':
+		p.i++
+		return nil
+	case p.i < len(p.s) && p.s[p.i] == closing:
+		return nil
+	default:
+		return fmt.Errorf("missing , or %c in TOML value", closing)
+	}
+}
+
+func (p *tomlParser) key() (string, error) {
+	if p.i < len(p.s) && (p.s[p.i] == '"' || p.s[p.i] == '\'') {
+		return p.str()
+	}
+	start := p.i
+	// Bare keys can only contain ASCII letters, digits, underscores and dashes.
+	for p.i < len(p.s) && strings.IndexByte("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	if p.i == start {
+		return "", errors.New("missing TOML key")
+	}
+	return p.s[start:p.i], nil
+}
+
+// str parses a basic or literal string. Multi-line strings are not supported.
+func (p *tomlParser) str() (string, error) {
+	quote := p.s[p.i]
+	start := p.i
+	for p.i++; p.i < len(p.s) && p.s[p.i] != quote; p.i++ {
+		if quote == '"' && p.s[p.i] == '\\' {
+			p.i++
+		}
+	}
+	if p.i >= len(p.s) {
+		return "", errors.New("unterminated TOML string")
+	}
+	p.i++
+	if quote == '\'' {
+		return p.s[start+1 : p.i-1], nil
+	}
+	// The escapes in TOML basic strings are a subset of the ones in Go.
+	return strconv.Unquote(p.s[start:p.i])
+}
@@ -0,0 +1,264 @@
+package action_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+const inputTOML = `[tool.poetry]
+name = "hype"
+version = "1.0.0" # bumped by release
+python = "^3.9"
+
+[tool.black]
+line-length = 88
+`
+
+const inputINI = `root = true
+
+[*]
+indent_style = space
+indent_size = 2
+
+[*.go]
+indent_style = tab
+`
+
+func TestINIAction(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		cfg         action.Config
+		wantMsg     string
+		wantMatches int
+		out         string
+	}{
+		{
+			name:        "set TOML string",
+			in:          inputTOML,
+			cfg:         action.Config{Type: "setTOML", Section: "tool.poetry", Key: "version", Value: "1.1.0", Path: "string.toml"},
+			wantMsg:     "Set `tool.poetry.version` to `\"1.1.0\"` in `string.toml`",
+			wantMatches: 1,
+			out: `[tool.poetry]
+name = "hype"
+version = "1.1.0" # bumped by release
+python = "^3.9"
+
+[tool.black]
+line-length = 88
+`,
+		},
+		{
+			name:        "add TOML key to section",
+			in:          inputTOML,
+			cfg:         action.Config{Type: "setTOML", Section: "tool.poetry", Key: "packages", Value: []any{"hype", 2}, Path: "add.toml"},
+			wantMsg:     "Added `tool.poetry.packages` with value `[\"hype\", 2]` to `add.toml`",
+			wantMatches: 0,
+			out: `[tool.poetry]
+name = "hype"
+version = "1.0.0" # bumped by release
+python = "^3.9"
+packages = ["hype", 2]
+
+[tool.black]
+line-length = 88
+`,
+		},
+		{
+			name:        "add TOML section",
+			in:          inputTOML,
+			cfg:         action.Config{Type: "setTOML", Section: "tool.isort", Key: "profile", Value: "black", Path: "section.toml"},
+			wantMsg:     "Added `tool.isort.profile` with value `\"black\"` to `section.toml`",
+			wantMatches: 0,
+			out: inputTOML + `
+[tool.isort]
+profile = "black"
+`,
+		},
+		{
+			name:        "set TOML unchanged",
+			in:          inputTOML,
+			cfg:         action.Config{Type: "setTOML", Section: "tool.black", Key: "line-length", Value: 88, Path: "unchanged.toml"},
+			wantMsg:     "`tool.black.line-length` is already `88` in `unchanged.toml`",
+			wantMatches: 1,
+			out:         inputTOML,
+		},
+		{
+			name:        "set TOML unchanged with different quotes",
+			in:          "[tool.poetry]\nversion = '1.0' # literal\n",
+			cfg:         action.Config{Type: "setTOML", Section: "tool.poetry", Key: "version", Value: "1.0", Path: "quotes.toml"},
+			wantMsg:     "`tool.poetry.version` is already `\"1.0\"` in `quotes.toml`",
+			wantMatches: 1,
+			out:         "[tool.poetry]\nversion = '1.0' # literal\n",
+		},
+		{
+			name:        "set TOML unchanged array",
+			in:          "[tool.poetry]\nclassifiers = [ 'Typing :: Typed', ]\nmeta = {stable = true, \"level\" = 0x2}\n",
+			cfg:         action.Config{Type: "setTOML", Section: "tool.poetry", Key: "classifiers", Value: []any{"Typing :: Typed"}, Path: "array.toml"},
+			wantMsg:     "`tool.poetry.classifiers` is already `[\"Typing :: Typed\"]` in `array.toml`",
+			wantMatches: 1,
+			out:         "[tool.poetry]\nclassifiers = [ 'Typing :: Typed', ]\nmeta = {stable = true, \"level\" = 0x2}\n",
+		},
+		{
+			name:        "set TOML unchanged inline table",
+			in:          "[tool.poetry]\nclassifiers = [ 'Typing :: Typed', ]\nmeta = {stable = true, \"level\" = 0x2}\n",
+			cfg:         action.Config{Type: "setTOML", Section: "tool.poetry", Key: "meta", Value: map[string]any{"level": 2, "stable": true}, Path: "table.toml"},
+			wantMsg:     "`tool.poetry.meta` is already `{ level = 2, stable = true }` in `table.toml`",
+			wantMatches: 1,
+			out:         "[tool.poetry]\nclassifiers = [ 'Typing :: Typed', ]\nmeta = {stable = true, \"level\" = 0x2}\n",
+		},
+		{
+			name:        "set INI unchanged",
+			in:          inputINI,
+			cfg:         action.Config{Type: "setINI", Section: "*.go", Key: "indent_style", Value: "tab", Path: "unchanged.ini"},
+			wantMsg:     "`*.go.indent_style` is already `tab` in `unchanged.ini`",
+			wantMatches: 1,
+			out:         inputINI,
+		},
+		{
+			name:        "set INI",
+			in:          inputINI,
+			cfg:         action.Config{Type: "setINI", Section: "*", Key: "indent_size", Value: 4, Path: "set.ini"},
+			wantMsg:     "Set `*.indent_size` to `4` in `set.ini`",
+			wantMatches: 1,
+			out: `root = true
+
+[*]
+indent_style = space
+indent_size = 4
+
+[*.go]
+indent_style = tab
+`,
+		},
+		{
+			name:        "set INI root",
+			in:          inputINI,
+			cfg:         action.Config{Type: "setINI", Key: "charset", Value: "utf-8", Path: "root.ini"},
+			wantMsg:     "Added `charset` with value `utf-8` to `root.ini`",
+			wantMatches: 0,
+			out: `root = true
+charset = utf-8
+
+[*]
+indent_style = space
+indent_size = 2
+
+[*.go]
+indent_style = tab
+`,
+		},
+		{
+			name: "skip multi-line values",
+			in: `[tool.poetry]
+packages = [
+  "version = 0.1.0",
+  ["hype"],
+]
+description = """
+version = "0.1.0"
+[tool.black]
+"""
+version = "1.0.0"
+
+[tool.black]
+line-length = 88
+`,
+			cfg:         action.Config{Type: "setTOML", Section: "tool.poetry", Key: "version", Value: "1.1.0", Path: "multiline.toml"},
+			wantMsg:     "Set `tool.poetry.version` to `\"1.1.0\"` in `multiline.toml`",
+			wantMatches: 1,
+			out: `[tool.poetry]
+packages = [
+  "version = 0.1.0",
+  ["hype"],
+]
+description = """
+version = "0.1.0"
+[tool.black]
+"""
+version = "1.1.0"
+
+[tool.black]
+line-length = 88
+`,
+		},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(td, tt.cfg.Path)
+			if err := os.WriteFile(path, []byte(tt.in), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if res.Matches != tt.wantMatches {
+				t.Errorf("got %d matches, want %d", res.Matches, tt.wantMatches)
+			}
+			if wantChanged := tt.in != tt.out; res.Changed != wantChanged {
+				t.Errorf("got changed %t, want %t", res.Changed, wantChanged)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if got := string(data); got != tt.out {
+				t.Errorf("got file\n\t%s\nwant\n\t%s", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestINIActionError(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		cfg  action.Config
+	}{
+		{
+			name: "multi-line value",
+			in:   "[tool.poetry]\npackages = [\n  \"hype\",\n]\n",
+			cfg:  action.Config{Type: "setTOML", Section: "tool.poetry", Key: "packages", Value: []any{"woke"}, Path: "multiline.toml"},
+		},
+		{
+			name: "required key missing",
+			in:   inputINI,
+			cfg:  action.Config{Type: "setINI", Section: "*.go", Key: "indent_size", Value: 4, Path: "required.ini", Required: true},
+		},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(td, tt.cfg.Path)
+			if err := os.WriteFile(path, []byte(tt.in), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			_, err = a.Run(context.Background(), pathTarget(td), action.Arguments{})
+			if err == nil {
+				t.Error("want non-nil error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,648 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type jsonActionType int
+
+const (
+	jsonSet jsonActionType = iota
+	jsonDelete
+	jsonMerge
+)
+
+// jsonAction is an action that edits a key in a JSON file.
+// The order of keys and the indentation of the file are preserved.
+type jsonAction struct {
+	typ      jsonActionType
+	path     string   // path to the JSON file
+	key      string   // the key from the config; for printing
+	keys     []string // the segments of key; empty means the root value
+	value    any      // the value to set or merge; not used by delete actions
+	required bool     // whether the key must already exist
+}
+
+func parseJSONAction(cfg Config) (Action, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("missing path for JSON action")
+	}
+	if cfg.Key == "" && cfg.Type != "mergeJSON" {
+		return nil, errors.New("missing key for JSON action")
+	}
+	keys, err := parseJSONKey(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	a := jsonAction{path: cfg.Path, key: cfg.Key, keys: keys, value: cfg.Value, required: cfg.Required}
+	switch cfg.Type {
+	case "setJSON":
+		if cfg.Value == nil {
+			return nil, errors.New("missing value for setJSON action")
+		}
+		a.typ = jsonSet
+	case "deleteJSON":
+		a.typ = jsonDelete
+	case "mergeJSON":
+		if _, ok := cfg.Value.(map[string]any); !ok {
+			return nil, errors.New("value for mergeJSON action must be a map")
+		}
+		a.typ = jsonMerge
+	default:
+		return nil, fmt.Errorf("unsupported JSON action type %s", cfg.Type)
+	}
+	return a, nil
+}
+
+// parseJSONKey splits a key into its segments. The key can either be
+// a JSON Pointer like /compilerOptions/target or a dotted path like compilerOptions.target.
+func parseJSONKey(key string) ([]string, error) {
+	if key == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(key, "/") {
+		return splitKey(key)
+	}
+	keys := strings.Split(key[1:], "/")
+	for i, k := range keys {
+		keys[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(k)
+	}
+	return keys, nil
+}
+
+func (a jsonAction) Run(_ context.Context, t Target, args Arguments) (Result, error) {
+	path := filepath.Join(t.Path(), a.path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	root, err := decodeJSONFile(data)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	expanded, err := expandValue(a.value, args)
+	if err != nil {
+		return Result{}, err
+	}
+	value := toJSONValue(expanded)
+
+	var existed, changed bool
+	switch a.typ {
+	case jsonSet:
+		existed, changed, err = setJSONValue(&root, a.keys, value)
+	case jsonDelete:
+		existed, err = deleteJSONValue(&root, a.keys)
+		changed = existed
+	case jsonMerge:
+		existed, changed, err = mergeJSONValue(&root, a.keys, value.(jsonObject))
+	default:
+		panic("impossible: invalid type")
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to update key %s in %s: %w", a.key, path, err)
+	}
+	if a.required && !existed {
+		return Result{}, fmt.Errorf("key %s not found in %s", a.key, path)
+	}
+
+	var msg string
+	switch {
+	case a.typ == jsonSet && existed:
+		msg = fmt.Sprintf("Set `%s` to `%s` in `%s`", a.key, formatValue(expanded), a.path)
+	case a.typ == jsonSet:
+		msg = fmt.Sprintf("Added `%s` with value `%s` to `%s`", a.key, formatValue(expanded), a.path)
+	case a.typ == jsonMerge && a.key == "":
+		msg = fmt.Sprintf("Merged `%s` into `%s`", formatValue(expanded), a.path)
+	case a.typ == jsonMerge:
+		msg = fmt.Sprintf("Merged `%s` into `%s` in `%s`", formatValue(expanded), a.key, a.path)
+	case existed:
+		msg = fmt.Sprintf("Deleted `%s` from `%s`", a.key, a.path)
+	default:
+		msg = fmt.Sprintf("Key `%s` not found in `%s`", a.key, a.path)
+	}
+	var matches int
+	if existed {
+		matches = 1
+	}
+	res := Result{Message: msg, Files: []string{a.path}, Matches: matches, Changed: changed}
+	if !changed {
+		return res, nil
+	}
+
+	output, err := encodeJSONFile(root, data)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to encode JSON for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, output, 0o644); err != nil {
+		return Result{}, fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return res, nil
+}
+
+func (a jsonAction) String() string {
+	switch a.typ {
+	case jsonSet:
+		return fmt.Sprintf("set JSON: %q\n  value: %s\n  path: %q", a.key, formatValue(a.value), a.path)
+	case jsonDelete:
+		return fmt.Sprintf("delete JSON: %q\n  path: %q", a.key, a.path)
+	case jsonMerge:
+		return fmt.Sprintf("merge JSON: %q\n  value: %s\n  path: %q", a.key, formatValue(a.value), a.path)
+	default:
+		panic("impossible: invalid type")
+	}
+}
+
+// jsonObject is a JSON object that keeps its members in their original order.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	Key   string
+	Value any
+}
+
+// index returns the index of the member with key or -1 if there is none.
+func (obj jsonObject) index(key string) int {
+	for i, m := range obj {
+		if m.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookupJSONValue returns a pointer to the value at keys within root and whether it existed.
+// If create is true, missing object members are created with a null value, otherwise nil is returned.
+func lookupJSONValue(root *any, keys []string, create bool) (*any, bool, error) {
+	cur := root
+	for i, k := range keys {
+		switch v := (*cur).(type) {
+		case jsonObject:
+			j := v.index(k)
+			if j < 0 {
+				if !create {
+					return nil, false, nil
+				}
+				var child any
+				if i < len(keys)-1 {
+					child = jsonObject{}
+				}
+				v = append(v, jsonMember{Key: k, Value: child})
+				*cur = v
+				j = len(v) - 1
+				if i == len(keys)-1 {
+					return &v[j].Value, false, nil
+				}
+			}
+			cur = &v[j].Value
+		case []any:
+			// - refers to the position after the last item as defined by JSON Pointer.
+			if k == "-" && create && i == len(keys)-1 {
+				v = append(v, nil)
+				*cur = v
+				return &v[len(v)-1], false, nil
+			}
+			idx, err := strconv.Atoi(k)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false, fmt.Errorf("invalid index %s for array of length %d", k, len(v))
+			}
+			cur = &v[idx]
+		default:
+			return nil, false, fmt.Errorf("%s is not an object or array", strings.Join(keys[:i], "."))
+		}
+	}
+	return cur, true, nil
+}
+
+// setJSONValue sets the value at keys within root, creating any missing objects.
+// It reports whether the key already existed and whether anything was changed.
+func setJSONValue(root *any, keys []string, value any) (existed, changed bool, err error) {
+	ptr, existed, err := lookupJSONValue(root, keys, true)
+	if err != nil {
+		return false, false, err
+	}
+	if existed && jsonEqual(*ptr, value) {
+		return true, false, nil
+	}
+	*ptr = value
+	return existed, true, nil
+}
+
+// deleteJSONValue deletes the value at keys within root. It reports whether the key existed.
+func deleteJSONValue(root *any, keys []string) (bool, error) {
+	parent, ok, err := lookupJSONValue(root, keys[:len(keys)-1], false)
+	if err != nil || !ok {
+		return false, err
+	}
+	last := keys[len(keys)-1]
+	switch v := (*parent).(type) {
+	case jsonObject:
+		if i := v.index(last); i >= 0 {
+			*parent = append(v[:i], v[i+1:]...)
+			return true, nil
+		}
+	case []any:
+		if i, err := strconv.Atoi(last); err == nil && i >= 0 && i < len(v) {
+			*parent = append(v[:i], v[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mergeJSONValue merges patch into the object at keys within root, creating it if it is missing.
+// Members of patch that are null are deleted. It reports whether the object already existed
+// and whether anything was changed.
+func mergeJSONValue(root *any, keys []string, patch jsonObject) (existed, changed bool, err error) {
+	ptr, existed, err := lookupJSONValue(root, keys, true)
+	if err != nil {
+		return false, false, err
+	}
+	if *ptr == nil {
+		*ptr = jsonObject{}
+	}
+	obj, ok := (*ptr).(jsonObject)
+	if !ok {
+		return false, false, fmt.Errorf("%s is not an object", strings.Join(keys, "."))
+	}
+	merged := mergeJSONObject(obj, patch)
+	*ptr = merged
+	return existed, !existed || !jsonEqual(obj, merged), nil
+}
+
+// mergeJSONObject returns a copy of obj with patch merged into it.
+func mergeJSONObject(obj, patch jsonObject) jsonObject {
+	merged := append(jsonObject(nil), obj...)
+	for _, m := range patch {
+		i := merged.index(m.Key)
+		switch {
+		case m.Value == nil && i >= 0:
+			merged = append(merged[:i], merged[i+1:]...)
+		case m.Value == nil:
+		case i < 0:
+			merged = append(merged, m)
+		default:
+			cur, curOK := merged[i].Value.(jsonObject)
+			p, pOK := m.Value.(jsonObject)
+			if curOK && pOK {
+				merged[i].Value = mergeJSONObject(cur, p)
+			} else {
+				merged[i].Value = m.Value
+			}
+		}
+	}
+	return merged
+}
+
+// toJSONValue converts a value decoded from the config into a value that can be
+// stored in a JSON tree. Map keys are sorted since their original order is not known.
+func toJSONValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		obj := make(jsonObject, len(keys))
+		for i, k := range keys {
+			obj[i] = jsonMember{Key: k, Value: toJSONValue(v[k])}
+		}
+		return obj
+	case []any:
+		arr := make([]any, len(v))
+		for i, e := range v {
+			arr[i] = toJSONValue(e)
+		}
+		return arr
+	default:
+		return value
+	}
+}
+
+// jsonEqual reports whether a and b encode to the same JSON.
+func jsonEqual(a, b any) bool {
+	var bufA, bufB bytes.Buffer
+	if encodeJSON(&bufA, a) != nil || encodeJSON(&bufB, b) != nil {
+		return false
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
+
+// decodeJSONFile decodes the JSON value in data. Numbers are kept as json.Number
+// so they are written back exactly as they were.
+func decodeJSONFile(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeJSON(dec)
+	if err == nil {
+		if _, err = dec.Token(); err == nil {
+			err = errors.New("unexpected data after top-level value")
+		} else if errors.Is(err, io.EOF) {
+			return v, nil
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected end of JSON input")
+	}
+	var serr *json.SyntaxError
+	if errors.As(err, &serr) {
+		offset := serr.Offset
+		if offset > int64(len(data)) {
+			offset = int64(len(data))
+		}
+		line := bytes.Count(data[:offset], []byte("\n")) + 1
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+	return nil, err
+}
+
+func decodeJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := jsonObject{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonMember{Key: tok.(string), Value: v})
+		}
+		// Consume the closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	default:
+		return tok, nil
+	}
+}
+
+// encodeJSON writes the compact encoding of v to buf.
+// HTML characters are not escaped since they are common in files like package.json.
+func encodeJSON(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case jsonObject:
+		buf.WriteByte('{')
+		for i, m := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, m.Key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeJSON(buf, m.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		// Encode always adds a newline.
+		buf.Truncate(buf.Len() - 1)
+	}
+	return nil
+}
+
+// encodeJSONFile encodes root so that it can replace the original contents of a file.
+// The indentation and trailing newline of the original are kept, and arrays and objects
+// that were on a single line stay on a single line. Other formatting is not kept,
+// so it should only be used if root was changed.
+func encodeJSONFile(root any, orig []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if indent := jsonIndent(orig); indent == "" {
+		if err := encodeJSON(&buf, root); err != nil {
+			return nil, err
+		}
+	} else {
+		layout, err := decodeJSONLayout(json.NewDecoder(bytes.NewReader(orig)), orig)
+		if err != nil {
+			return nil, err
+		}
+		if err := encodeJSONIndent(&buf, root, layout, indent, 0); err != nil {
+			return nil, err
+		}
+	}
+	if bytes.HasSuffix(orig, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonLayout describes how an array or object was written in the original file.
+type jsonLayout struct {
+	raw     []byte                 // the original text of the value
+	inline  bool                   // whether the value was written on a single line
+	members map[string]*jsonLayout // the layouts of the members of an object
+	items   []*jsonLayout          // the layouts of the items of an array
+}
+
+// decodeJSONLayout reads the next value from dec and returns its layout.
+// data must be the input of dec. Scalars have no layout so nil is returned for them.
+func decodeJSONLayout(dec *json.Decoder, data []byte) (*jsonLayout, error) {
+	// The offset is the end of the previous token, skip over any separators to find the value.
+	start := int(dec.InputOffset())
+	for start < len(data) && strings.IndexByte(" \t\r\n,:", data[start]) >= 0 {
+		start++
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	var l jsonLayout
+	switch tok {
+	case json.Delim('{'):
+		l.members = make(map[string]*jsonLayout)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			child, err := decodeJSONLayout(dec, data)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := l.members[key.(string)]; !ok {
+				l.members[key.(string)] = child
+			}
+		}
+	case json.Delim('['):
+		for dec.More() {
+			child, err := decodeJSONLayout(dec, data)
+			if err != nil {
+				return nil, err
+			}
+			l.items = append(l.items, child)
+		}
+	default:
+		return nil, nil
+	}
+	// Consume the closing delimiter.
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	l.raw = data[start:dec.InputOffset()]
+	// Empty values are always written inline so it says nothing about how they should be formatted.
+	l.inline = bytes.IndexByte(l.raw, '\n') < 0 && len(l.members)+len(l.items) > 0
+	return &l, nil
+}
+
+// encodeJSONIndent writes v to buf indented by indent, starting at the given depth.
+// Arrays and objects that were inline in layout are written inline. If they are unchanged
+// their original text is used.
+func encodeJSONIndent(buf *bytes.Buffer, v any, layout *jsonLayout, indent string, depth int) error {
+	if layout != nil && layout.inline {
+		if old, err := decodeJSONFile(layout.raw); err == nil && jsonEqual(old, v) {
+			buf.Write(layout.raw)
+			return nil
+		}
+		return encodeJSONInline(buf, v)
+	}
+	newline := func(depth int) {
+		buf.WriteByte('\n')
+		buf.WriteString(strings.Repeat(indent, depth))
+	}
+	switch v := v.(type) {
+	case jsonObject:
+		if len(v) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteByte('{')
+		for i, m := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			if err := encodeJSON(buf, m.Key); err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			var child *jsonLayout
+			if layout != nil {
+				child = layout.members[m.Key]
+			}
+			if err := encodeJSONIndent(buf, m.Value, child, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		buf.WriteByte('}')
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			var child *jsonLayout
+			if layout != nil && i < len(layout.items) {
+				child = layout.items[i]
+			}
+			if err := encodeJSONIndent(buf, e, child, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		buf.WriteByte(']')
+	default:
+		return encodeJSON(buf, v)
+	}
+	return nil
+}
+
+// encodeJSONInline writes v to buf on a single line with a space after each separator.
+func encodeJSONInline(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case jsonObject:
+		buf.WriteByte('{')
+		for i, m := range v {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := encodeJSON(buf, m.Key); err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			if err := encodeJSONInline(buf, m.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := encodeJSONInline(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return encodeJSON(buf, v)
+	}
+	return nil
+}
+
+// jsonIndent returns the indentation used in data or an empty string if data is not indented.
+func jsonIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,341 @@
+package action_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+const inputJSON = `{
+  "name": "hype",
+  "version": "1.0.0",
+  "scripts": {
+    "build": "tsc && webpack",
+    "test": "jest"
+  },
+  "dependencies": {
+    "@babel/core": "^7.0.0",
+    "express": "^4.17.1"
+  },
+  "files": [
+    "dist"
+  ]
+}
+`
+
+// inputJSONInline has arrays and objects on a single line that must stay that way.
+const inputJSONInline = `{
+    "compilerOptions": {
+        "target": "es2017",
+        "lib": ["dom", "es2017"],
+        "paths": {"@/*": ["src/*"]}
+    }
+}
+`
+
+func TestJSONAction(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		cfg         action.Config
+		vars        map[string]string
+		wantMsg     string
+		wantMatches int
+		out         string
+	}{
+		{
+			name: "set existing key",
+			in:   inputJSON,
+			cfg: action.Config{
+				Type:  "setJSON",
+				Key:   "scripts.test",
+				Value: "jest --ci --runInBand",
+				Path:  "set_existing.json",
+			},
+			wantMsg:     "Set `scripts.test` to `\"jest --ci --runInBand\"` in `set_existing.json`",
+			wantMatches: 1,
+			out: `{
+  "name": "hype",
+  "version": "1.0.0",
+  "scripts": {
+    "build": "tsc && webpack",
+    "test": "jest --ci --runInBand"
+  },
+  "dependencies": {
+    "@babel/core": "^7.0.0",
+    "express": "^4.17.1"
+  },
+  "files": [
+    "dist"
+  ]
+}
+`,
+		},
+		{
+			name: "set with JSON Pointer",
+			in:   inputJSON,
+			cfg: action.Config{
+				Type:  "setJSON",
+				Key:   "/dependencies/@babel~1core",
+				Value: "^${VERSION}",
+				Path:  "set_pointer.json",
+			},
+			vars:        map[string]string{"VERSION": "7.20.0"},
+			wantMsg:     "Set `/dependencies/@babel~1core` to `\"^7.20.0\"` in `set_pointer.json`",
+			wantMatches: 1,
+			out: `{
+  "name": "hype",
+  "version": "1.0.0",
+  "scripts": {
+    "build": "tsc && webpack",
+    "test": "jest"
+  },
+  "dependencies": {
+    "@babel/core": "^7.20.0",
+    "express": "^4.17.1"
+  },
+  "files": [
+    "dist"
+  ]
+}
+`,
+		},
+		{
+			name: "add missing key",
+			in:   "{\n\t\"compilerOptions\": {\n\t\t\"target\": \"es2017\"\n\t}\n}\n",
+			cfg: action.Config{
+				Type:  "setJSON",
+				Key:   "compilerOptions.strict",
+				Value: true,
+				Path:  "tsconfig.json",
+			},
+			wantMsg:     "Added `compilerOptions.strict` with value `true` to `tsconfig.json`",
+			wantMatches: 0,
+			out:         "{\n\t\"compilerOptions\": {\n\t\t\"target\": \"es2017\",\n\t\t\"strict\": true\n\t}\n}\n",
+		},
+		{
+			name: "set unchanged",
+			in:   inputJSON,
+			cfg: action.Config{
+				Type:  "setJSON",
+				Key:   "version",
+				Value: "1.0.0",
+				Path:  "set_unchanged.json",
+			},
+			wantMsg:     "Set `version` to `\"1.0.0\"` in `set_unchanged.json`",
+			wantMatches: 1,
+			out:         inputJSON,
+		},
+		{
+			name: "delete key",
+			in:   inputJSON,
+			cfg: action.Config{
+				Type: "deleteJSON",
+				Key:  "files",
+				Path: "delete_key.json",
+			},
+			wantMsg:     "Deleted `files` from `delete_key.json`",
+			wantMatches: 1,
+			out: `{
+  "name": "hype",
+  "version": "1.0.0",
+  "scripts": {
+    "build": "tsc && webpack",
+    "test": "jest"
+  },
+  "dependencies": {
+    "@babel/core": "^7.0.0",
+    "express": "^4.17.1"
+  }
+}
+`,
+		},
+		{
+			name: "delete missing key",
+			in:   inputJSON,
+			cfg: action.Config{
+				Type: "deleteJSON",
+				Key:  "scripts.lint",
+				Path: "delete_missing.json",
+			},
+			wantMsg:     "Key `scripts.lint` not found in `delete_missing.json`",
+			wantMatches: 0,
+			out:         inputJSON,
+		},
+		{
+			name: "merge object",
+			in:   inputJSON,
+			cfg: action.Config{
+				Type: "mergeJSON",
+				Key:  "scripts",
+				Value: map[string]any{
+					"lint": "eslint .",
+					"test": nil,
+				},
+				Path: "merge.json",
+			},
+			wantMsg:     "Merged `{\"lint\":\"eslint .\",\"test\":null}` into `scripts` in `merge.json`",
+			wantMatches: 1,
+			out: `{
+  "name": "hype",
+  "version": "1.0.0",
+  "scripts": {
+    "build": "tsc && webpack",
+    "lint": "eslint ."
+  },
+  "dependencies": {
+    "@babel/core": "^7.0.0",
+    "express": "^4.17.1"
+  },
+  "files": [
+    "dist"
+  ]
+}
+`,
+		},
+		{
+			name: "merge into root",
+			in:   `{"name":"hype","private":false}`,
+			cfg: action.Config{
+				Type:  "mergeJSON",
+				Value: map[string]any{"private": true},
+				Path:  "merge_root.json",
+			},
+			wantMsg:     "Merged `{\"private\":true}` into `merge_root.json`",
+			wantMatches: 1,
+			out:         `{"name":"hype","private":true}`,
+		},
+		{
+			name:        "keep inline arrays",
+			in:          inputJSONInline,
+			cfg:         action.Config{Type: "setJSON", Key: "compilerOptions.target", Value: "es2020", Path: "inline_keep.json"},
+			wantMsg:     "Set `compilerOptions.target` to `\"es2020\"` in `inline_keep.json`",
+			wantMatches: 1,
+			out: `{
+    "compilerOptions": {
+        "target": "es2020",
+        "lib": ["dom", "es2017"],
+        "paths": {"@/*": ["src/*"]}
+    }
+}
+`,
+		},
+		{
+			name:        "set in inline array",
+			in:          inputJSONInline,
+			cfg:         action.Config{Type: "setJSON", Key: "compilerOptions.lib.1", Value: "es2020", Path: "inline_set.json"},
+			wantMsg:     "Set `compilerOptions.lib.1` to `\"es2020\"` in `inline_set.json`",
+			wantMatches: 1,
+			out: `{
+    "compilerOptions": {
+        "target": "es2017",
+        "lib": ["dom", "es2020"],
+        "paths": {"@/*": ["src/*"]}
+    }
+}
+`,
+		},
+		{
+			name:        "add to inline object",
+			in:          inputJSONInline,
+			cfg:         action.Config{Type: "setJSON", Key: "compilerOptions.paths.~", Value: []any{"test"}, Path: "inline_add.json"},
+			wantMsg:     "Added `compilerOptions.paths.~` with value `[\"test\"]` to `inline_add.json`",
+			wantMatches: 0,
+			out: `{
+    "compilerOptions": {
+        "target": "es2017",
+        "lib": ["dom", "es2017"],
+        "paths": {"@/*": ["src/*"], "~": ["test"]}
+    }
+}
+`,
+		},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(td, tt.cfg.Path)
+			if err := os.WriteFile(path, []byte(tt.in), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if res.Matches != tt.wantMatches {
+				t.Errorf("got %d matches, want %d", res.Matches, tt.wantMatches)
+			}
+			if wantChanged := tt.in != tt.out; res.Changed != wantChanged {
+				t.Errorf("got changed %t, want %t", res.Changed, wantChanged)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if got := string(data); got != tt.out {
+				t.Errorf("got file\n\t%s\nwant\n\t%s", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestJSONActionError(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		cfg  action.Config
+	}{
+		{
+			name: "invalid JSON",
+			in:   "{\n  \"name\": \"hype\",\n}\n",
+			cfg:  action.Config{Type: "setJSON", Key: "name", Value: "woke", Path: "invalid.json"},
+		},
+		{
+			name: "trailing data",
+			in:   "{}\n{}\n",
+			cfg:  action.Config{Type: "setJSON", Key: "name", Value: "woke", Path: "trailing.json"},
+		},
+		{
+			name: "required key missing",
+			in:   inputJSON,
+			cfg:  action.Config{Type: "deleteJSON", Key: "scripts.lint", Path: "required.json", Required: true},
+		},
+		{
+			name: "merge into non object",
+			in:   inputJSON,
+			cfg:  action.Config{Type: "mergeJSON", Key: "files", Value: map[string]any{"a": "b"}, Path: "merge.json"},
+		},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(td, tt.cfg.Path)
+			if err := os.WriteFile(path, []byte(tt.in), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			_, err = a.Run(context.Background(), pathTarget(td), action.Arguments{})
+			if err == nil {
+				t.Error("want non-nil error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,716 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+type yamlActionType int
+
+const (
+	yamlSet yamlActionType = iota
+	yamlDelete
+)
+
+// yamlAction is an action that edits a key in a YAML file.
+// It operates on the YAML node tree so that comments and key order are preserved.
+type yamlAction struct {
+	typ      yamlActionType
+	path     string   // path to the YAML file
+	key      string   // the key from the config; for printing
+	keys     []string // the segments of key
+	value    any      // the value to set; only used by set actions
+	required bool     // whether the key must already exist
+}
+
+func parseYAMLAction(cfg Config) (Action, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("missing path for YAML action")
+	}
+	if cfg.Key == "" {
+		return nil, errors.New("missing key for YAML action")
+	}
+	keys, err := splitKey(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	a := yamlAction{path: cfg.Path, key: cfg.Key, keys: keys, required: cfg.Required}
+	switch cfg.Type {
+	case "setYAML":
+		if cfg.Value == nil {
+			return nil, errors.New("missing value for setYAML action")
+		}
+		a.typ = yamlSet
+		a.value = cfg.Value
+	case "deleteYAML":
+		a.typ = yamlDelete
+	default:
+		return nil, fmt.Errorf("unsupported YAML action type %s", cfg.Type)
+	}
+	return a, nil
+}
+
+// splitKey splits a dotted key like services.api.image into its segments.
+// Numeric segments are used as indices in sequences.
+func splitKey(key string) ([]string, error) {
+	keys := strings.Split(key, ".")
+	for _, k := range keys {
+		if k == "" {
+			return nil, fmt.Errorf("invalid key %q, must not contain empty segments", key)
+		}
+	}
+	return keys, nil
+}
+
+func (a yamlAction) Run(_ context.Context, t Target, args Arguments) (Result, error) {
+	path := filepath.Join(t.Path(), a.path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to parse YAML in %s: %w", path, err)
+		}
+		docs = append(docs, &doc)
+	}
+
+	var value any
+	var valueNode *yaml.Node
+	var newDoc bool
+	if a.typ == yamlSet {
+		value, err = expandValue(a.value, args)
+		if err != nil {
+			return Result{}, err
+		}
+		valueNode = &yaml.Node{}
+		if err := valueNode.Encode(value); err != nil {
+			return Result{}, fmt.Errorf("failed to encode value for key %s: %w", a.key, err)
+		}
+		if len(docs) == 0 {
+			// Empty file, start a new document.
+			docs = append(docs, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}})
+			newDoc = true
+		}
+	}
+
+	var found int
+	var changed bool
+	e := newYAMLEditor(data)
+	for _, doc := range docs {
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := doc.Content[0]
+		switch a.typ {
+		case yamlSet:
+			// Only create missing keys in files with a single document, otherwise
+			// it's ambiguous which documents the key belongs in.
+			existed, ch, err := e.set(root, a.keys, valueNode, value, len(docs) == 1)
+			if err != nil {
+				return Result{}, fmt.Errorf("failed to set key %s in %s: %w", a.key, path, err)
+			}
+			if existed {
+				found++
+			}
+			changed = changed || ch
+		case yamlDelete:
+			deleted, err := e.delete(root, a.keys)
+			if err != nil {
+				return Result{}, fmt.Errorf("failed to delete key %s in %s: %w", a.key, path, err)
+			}
+			if deleted {
+				found++
+				changed = true
+			}
+		default:
+			panic("impossible: invalid type")
+		}
+	}
+	if a.required && found == 0 {
+		return Result{}, fmt.Errorf("key %s not found in %s", a.key, path)
+	}
+
+	var msg string
+	switch {
+	case a.typ == yamlSet && found > 0:
+		msg = fmt.Sprintf("Set `%s` to `%s` in `%s`", a.key, formatValue(value), a.path)
+	case a.typ == yamlSet && changed:
+		msg = fmt.Sprintf("Added `%s` with value `%s` to `%s`", a.key, formatValue(value), a.path)
+	case a.typ == yamlDelete && found > 0:
+		msg = fmt.Sprintf("Deleted `%s` from `%s`", a.key, a.path)
+	default:
+		msg = fmt.Sprintf("Key `%s` not found in `%s`", a.key, a.path)
+	}
+	res := Result{Message: msg, Files: []string{a.path}, Matches: found, Changed: changed}
+	if !changed {
+		return res, nil
+	}
+
+	output := e.apply()
+	if newDoc {
+		// There was nothing to edit, append the new document after any comments.
+		text, err := e.render(docs[0].Content[0], 0, false)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to encode YAML for %s: %w", path, err)
+		}
+		if len(output) > 0 && output[len(output)-1] != '\n' {
+			output = append(output, '\n')
+		}
+		output = append(output, text+"\n"...)
+	}
+	if err := os.WriteFile(path, output, 0o644); err != nil {
+		return Result{}, fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return res, nil
+}
+
+func (a yamlAction) String() string {
+	switch a.typ {
+	case yamlSet:
+		return fmt.Sprintf("set YAML: %q\n  value: %s\n  path: %q", a.key, formatValue(a.value), a.path)
+	case yamlDelete:
+		return fmt.Sprintf("delete YAML: %q\n  path: %q", a.key, a.path)
+	default:
+		panic("impossible: invalid type")
+	}
+}
+
+// yamlEditor applies changes to the nodes of YAML documents and records the same
+// changes as edits to the original text. Only the changed parts of the file are
+// rewritten so that formatting like blank lines and indentation is preserved.
+type yamlEditor struct {
+	data   []byte
+	lines  []int // the offset of the start of each line
+	indent int   // the indentation used by the file, for rendering new nodes
+	edits  []yamlEdit
+}
+
+// yamlEdit replaces the text between start and end with text.
+type yamlEdit struct {
+	start, end int
+	text       string
+}
+
+func newYAMLEditor(data []byte) *yamlEditor {
+	e := &yamlEditor{data: data, lines: []int{0}, indent: yamlIndent(data)}
+	for i, c := range data {
+		if c == '\n' {
+			e.lines = append(e.lines, i+1)
+		}
+	}
+	return e
+}
+
+// set sets the value at keys within root. It reports whether the key already existed
+// and whether anything was changed. If create is true, missing keys are created.
+func (e *yamlEditor) set(root *yaml.Node, keys []string, valueNode *yaml.Node, value any, create bool) (existed, changed bool, err error) {
+	node := root
+	var flowRoot *yaml.Node // the outermost flow collection containing the node, if any
+	for i, k := range keys {
+		if flowRoot == nil && node.Style&yaml.FlowStyle != 0 {
+			flowRoot = node
+		}
+		last := i == len(keys)-1
+		var key, child *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			if j := yamlMappingIndex(node, k); j >= 0 {
+				key, child = node.Content[j], node.Content[j+1]
+				break
+			}
+			if !create {
+				return false, false, nil
+			}
+			// Create all the missing keys at once so the new entry is added in a single place.
+			v := copyYAMLNode(valueNode)
+			for j := len(keys) - 1; j > i; j-- {
+				v = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{yamlKey(keys[j]), v}}
+			}
+			return false, true, e.insert(node, flowRoot, yamlKey(k), v)
+		case yaml.SequenceNode:
+			idx, err := strconv.Atoi(k)
+			if err != nil || idx < 0 || idx >= len(node.Content) {
+				return false, false, fmt.Errorf("invalid index %s for sequence of length %d", k, len(node.Content))
+			}
+			child = node.Content[idx]
+		default:
+			return false, false, fmt.Errorf("%s is not a mapping or sequence", strings.Join(keys[:i], "."))
+		}
+		if !last {
+			node = child
+			continue
+		}
+		var cur any
+		if err := child.Decode(&cur); err == nil && reflect.DeepEqual(cur, value) {
+			return true, false, nil
+		}
+		if flowRoot != nil {
+			return true, true, e.rerender(flowRoot, func() { replaceYAMLNode(child, valueNode) })
+		}
+		return true, true, e.replace(key, child, valueNode)
+	}
+	panic("impossible: keys must not be empty")
+}
+
+// replace replaces the value node old with a copy of valueNode.
+// key is the key of old if it is in a mapping or nil if it is in a sequence.
+func (e *yamlEditor) replace(key, old, valueNode *yaml.Node) error {
+	start, end := e.start(old), e.end(old)
+	wasBlock := isBlockCollection(old)
+	indent := old.Column - 1
+	replaceYAMLNode(old, valueNode)
+	n := old
+
+	var text string
+	var err error
+	switch {
+	case wasBlock && isCollection(n) && len(n.Content) > 0:
+		// Replace the block in place, starting at the same column.
+		text, err = e.render(n, indent, false)
+	case wasBlock:
+		// The new value goes on the line of the key or sequence indicator instead of below it.
+		if key != nil {
+			start = e.end(key)
+			start += bytes.IndexByte(e.data[start:], ':') + 1
+		} else {
+			start = bytes.LastIndexByte(e.data[:start], '-') + 1
+		}
+		text, err = e.render(n, e.lineIndent(start), true)
+		text = " " + text
+	default:
+		text, err = e.render(n, e.lineIndent(start), true)
+		if start == end {
+			// An empty value, like the null in "key:".
+			text = " " + text
+		}
+	}
+	if err != nil {
+		return err
+	}
+	e.edits = append(e.edits, yamlEdit{start: start, end: end, text: text})
+	return nil
+}
+
+// insert adds key and value to the end of the mapping parent.
+// flowRoot is the outermost flow collection containing parent, if any.
+func (e *yamlEditor) insert(parent, flowRoot, key, value *yaml.Node) error {
+	add := func() { parent.Content = append(parent.Content, key, value) }
+	if parent.Line == 0 {
+		// The root of a new document which is rendered as a whole.
+		add()
+		return nil
+	}
+	if flowRoot == nil && !isBlockCollection(parent) {
+		flowRoot = parent
+	}
+	if flowRoot != nil {
+		return e.rerender(flowRoot, add)
+	}
+
+	// Add a line after the last entry of the mapping.
+	pos := e.nextLine(e.end(parent))
+	indent := parent.Column - 1
+	add()
+	text, err := e.render(&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, value}}, indent, false)
+	if err != nil {
+		return err
+	}
+	text = strings.Repeat(" ", indent) + text + "\n"
+	if pos == len(e.data) && pos > 0 && e.data[pos-1] != '\n' {
+		text = "\n" + text
+	}
+	e.edits = append(e.edits, yamlEdit{start: pos, end: pos, text: text})
+	return nil
+}
+
+// delete deletes the value at keys within root. It reports whether the key existed.
+func (e *yamlEditor) delete(root *yaml.Node, keys []string) (bool, error) {
+	parent := root
+	var parentKey, flowRoot *yaml.Node
+	for _, k := range keys[:len(keys)-1] {
+		if flowRoot == nil && parent.Style&yaml.FlowStyle != 0 {
+			flowRoot = parent
+		}
+		parentKey = nil
+		if parent.Kind == yaml.MappingNode {
+			if j := yamlMappingIndex(parent, k); j >= 0 {
+				parentKey = parent.Content[j]
+			}
+		}
+		parent = yamlChild(parent, k)
+		if parent == nil {
+			return false, nil
+		}
+	}
+	if flowRoot == nil && parent.Style&yaml.FlowStyle != 0 {
+		flowRoot = parent
+	}
+
+	// Find the nodes to remove, a key and value for mappings or an item for sequences.
+	last := keys[len(keys)-1]
+	i, n := -1, 1
+	switch parent.Kind {
+	case yaml.MappingNode:
+		i, n = yamlMappingIndex(parent, last), 2
+	case yaml.SequenceNode:
+		if idx, err := strconv.Atoi(last); err == nil && idx >= 0 && idx < len(parent.Content) {
+			i = idx
+		}
+	}
+	if i < 0 {
+		return false, nil
+	}
+	first, lastNode := parent.Content[i], parent.Content[i+n-1]
+	remove := func() { parent.Content = append(parent.Content[:i], parent.Content[i+n:]...) }
+	if flowRoot != nil {
+		return true, e.rerender(flowRoot, remove)
+	}
+	if len(parent.Content) == n && parent != root {
+		// Nothing is left so the parent becomes an empty collection.
+		return true, e.replace(parentKey, parent, &yaml.Node{Kind: parent.Kind, Style: yaml.FlowStyle})
+	}
+
+	// Remove the lines of the entry if it is on its own lines, otherwise
+	// the entry shares a line with a sequence indicator or is the only one.
+	start := e.start(first)
+	if parent.Kind == yaml.SequenceNode {
+		start = bytes.LastIndexByte(e.data[:start], '-')
+	}
+	lineStart := e.lines[e.line(start)]
+	if len(parent.Content) == n || start < 0 || len(bytes.TrimSpace(e.data[lineStart:start])) > 0 {
+		return true, e.rerender(parent, remove)
+	}
+	e.edits = append(e.edits, yamlEdit{start: lineStart, end: e.nextLine(e.end(lastNode))})
+	remove()
+	return true, nil
+}
+
+// rerender calls mutate to change node and replaces the text of node by rendering it again.
+// It is used for changes that can't be made by only editing the changed parts of node.
+func (e *yamlEditor) rerender(node *yaml.Node, mutate func()) error {
+	start, end := e.start(node), e.end(node)
+	flow := !isBlockCollection(node)
+	indent := node.Column - 1
+	mutate()
+	text, err := e.render(node, indent, flow)
+	if err != nil {
+		return err
+	}
+	e.edits = append(e.edits, yamlEdit{start: start, end: end, text: text})
+	return nil
+}
+
+// render encodes node so that it can be placed in the file at a column after indent spaces.
+// The first line is not indented and there is no trailing newline.
+// If flow is true, collections are encoded in flow style so that they fit on one line.
+func (e *yamlEditor) render(node *yaml.Node, indent int, flow bool) (string, error) {
+	// Comments attached to node are outside of the text being replaced so don't encode them again.
+	n := *node
+	n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+	if len(n.Content) > 0 {
+		n.Content = append([]*yaml.Node(nil), n.Content...)
+		first := *n.Content[0]
+		first.HeadComment = ""
+		n.Content[0] = &first
+	}
+	if flow && isCollection(&n) {
+		n.Style |= yaml.FlowStyle
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(e.indent)
+	if err := enc.Encode(&n); err != nil {
+		return "", fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	text := strings.TrimSuffix(buf.String(), "\n")
+	return strings.ReplaceAll(text, "\n", "\n"+strings.Repeat(" ", indent)), nil
+}
+
+// apply returns the data with all edits applied.
+func (e *yamlEditor) apply() []byte {
+	sort.Slice(e.edits, func(i, j int) bool { return e.edits[i].start < e.edits[j].start })
+	var out []byte
+	prev := 0
+	for _, ed := range e.edits {
+		out = append(out, e.data[prev:ed.start]...)
+		out = append(out, ed.text...)
+		prev = ed.end
+	}
+	return append(out, e.data[prev:]...)
+}
+
+// start returns the offset of the start of node in the data.
+func (e *yamlEditor) start(node *yaml.Node) int {
+	off := e.lines[node.Line-1]
+	// Columns count characters, not bytes.
+	for col := 1; col < node.Column && off < len(e.data) && e.data[off] != '\n'; col++ {
+		_, size := utf8.DecodeRune(e.data[off:])
+		off += size
+	}
+	return off
+}
+
+// end returns the offset just after the text of node in the data.
+// Any comment after the node is not included.
+func (e *yamlEditor) end(node *yaml.Node) int {
+	start := e.start(node)
+	switch {
+	case isBlockCollection(node):
+		return e.end(node.Content[len(node.Content)-1])
+	case isCollection(node):
+		return e.flowEnd(start)
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return e.blockScalarEnd(start)
+	case node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0:
+		return e.quotedEnd(start)
+	default:
+		// A plain scalar or alias ends at a mapping indicator, a comment or the end of the line.
+		end := start
+		for ; end < len(e.data) && e.data[end] != '\n'; end++ {
+			if e.data[end] == '#' && end > start && isYAMLSpace(e.data[end-1]) {
+				break
+			}
+			if e.data[end] == ':' && (end+1 == len(e.data) || isYAMLSpace(e.data[end+1]) || e.data[end+1] == '\n' || e.data[end+1] == '\r') {
+				break
+			}
+		}
+		for end > start && isYAMLSpace(e.data[end-1]) {
+			end--
+		}
+		return end
+	}
+}
+
+// quotedEnd returns the offset after the quoted string starting at start.
+func (e *yamlEditor) quotedEnd(start int) int {
+	q := e.data[start]
+	for i := start + 1; i < len(e.data); i++ {
+		switch {
+		case q == '"' && e.data[i] == '\\':
+			i++
+		case e.data[i] == q && q == '\'' && i+1 < len(e.data) && e.data[i+1] == '\'':
+			// An escaped single quote.
+			i++
+		case e.data[i] == q:
+			return i + 1
+		}
+	}
+	return len(e.data)
+}
+
+// flowEnd returns the offset after the flow collection starting at start.
+func (e *yamlEditor) flowEnd(start int) int {
+	depth := 0
+	for i := start; i < len(e.data); i++ {
+		switch c := e.data[i]; {
+		case c == '"' || c == '\'':
+			i = e.quotedEnd(i) - 1
+		case c == '#' && i > start && isYAMLSpace(e.data[i-1]):
+			for i < len(e.data) && e.data[i] != '\n' {
+				i++
+			}
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(e.data)
+}
+
+// blockScalarEnd returns the offset after the last line of the literal
+// or folded block scalar whose header starts at start.
+func (e *yamlEditor) blockScalarEnd(start int) int {
+	indent := e.lineIndent(start)
+	end := e.nextLine(start) - 1
+	if end < start {
+		end = len(e.data)
+	}
+	for l := e.line(start) + 1; l < len(e.lines); l++ {
+		off := e.lines[l]
+		text := bytes.TrimRight(e.data[off:e.nextLine(off)], "\r\n")
+		if len(bytes.TrimSpace(text)) == 0 {
+			continue
+		}
+		if e.lineIndent(off) <= indent {
+			break
+		}
+		end = off + len(text)
+	}
+	return end
+}
+
+// line returns the 0-based line containing off.
+func (e *yamlEditor) line(off int) int {
+	return sort.Search(len(e.lines), func(i int) bool { return e.lines[i] > off }) - 1
+}
+
+// lineIndent returns the indentation of the line containing off.
+func (e *yamlEditor) lineIndent(off int) int {
+	start := e.lines[e.line(off)]
+	i := start
+	for i < len(e.data) && e.data[i] == ' ' {
+		i++
+	}
+	return i - start
+}
+
+// nextLine returns the offset of the start of the line after the one containing off.
+func (e *yamlEditor) nextLine(off int) int {
+	if i := bytes.IndexByte(e.data[off:], '\n'); i >= 0 {
+		return off + i + 1
+	}
+	return len(e.data)
+}
+
+func isYAMLSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isCollection(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode
+}
+
+// isBlockCollection reports whether node is a mapping or sequence in block style.
+// Empty collections are always in flow style.
+func isBlockCollection(node *yaml.Node) bool {
+	return isCollection(node) && node.Style&yaml.FlowStyle == 0 && len(node.Content) > 0
+}
+
+// replaceYAMLNode replaces old with a copy of valueNode.
+// Comments attached to old are kept.
+func replaceYAMLNode(old, valueNode *yaml.Node) {
+	n := copyYAMLNode(valueNode)
+	n.HeadComment = old.HeadComment
+	n.LineComment = old.LineComment
+	n.FootComment = old.FootComment
+	// Keep the quoting style of strings, e.g. image tags that are quoted.
+	if old.Kind == yaml.ScalarNode && n.Kind == yaml.ScalarNode && n.Tag == "!!str" && old.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		n.Style = old.Style
+	}
+	*old = *n
+}
+
+// yamlChild returns the child of node identified by key or nil if it doesn't exist.
+// For sequences key is the index of the child.
+func yamlChild(node *yaml.Node, key string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return yamlMappingValue(node, key)
+	case yaml.SequenceNode:
+		idx, err := strconv.Atoi(key)
+		if err != nil || idx < 0 || idx >= len(node.Content) {
+			return nil
+		}
+		return node.Content[idx]
+	default:
+		return nil
+	}
+}
+
+// yamlMappingValue returns the value for key in the mapping node or nil if it doesn't exist.
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if i := yamlMappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
+
+// yamlMappingIndex returns the index of key in the content of the mapping node or -1 if it doesn't exist.
+func yamlMappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlKey returns a node for a new mapping key.
+func yamlKey(key string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+}
+
+// copyYAMLNode returns a shallow copy of n so it can be inserted in multiple places.
+func copyYAMLNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	return &c
+}
+
+// yamlIndent returns the number of spaces used for indentation in data.
+// It defaults to 2 if data has no indented lines.
+func yamlIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if n := len(line) - len(trimmed); n > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return n
+		}
+	}
+	return 2
+}
+
+// expandValue expands variables in all strings within value.
+func expandValue(value any, args Arguments) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return expand(v, args)
+	case []any:
+		expanded := make([]any, len(v))
+		for i, e := range v {
+			ev, err := expandValue(e, args)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = ev
+		}
+		return expanded, nil
+	case map[string]any:
+		expanded := make(map[string]any, len(v))
+		for k, e := range v {
+			ev, err := expandValue(e, args)
+			if err != nil {
+				return nil, err
+			}
+			expanded[k] = ev
+		}
+		return expanded, nil
+	default:
+		return value, nil
+	}
+}
+
+// formatValue returns a compact single line representation of value for messages.
+func formatValue(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
@@ -0,0 +1,383 @@
+package action_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+const inputYAML = `# Services for the hype stack.
+services:
+    api:
+        image: "hype/api:1.0" # pinned
+        ports:
+            - 8080
+    # The worker processes jobs.
+    worker:
+        image: hype/worker:1.0
+`
+
+// inputYAMLFormatted has formatting that must be kept when editing the file.
+const inputYAMLFormatted = `name: hype
+
+steps:
+- run: make build
+- run: |
+    make test
+    make lint
+
+env: {GO: "1.19", CGO_ENABLED: 0}
+
+cache:
+`
+
+func TestYAMLAction(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		cfg         action.Config
+		vars        map[string]string
+		wantMsg     string
+		wantMatches int
+		out         string
+	}{
+		{
+			name: "set existing key",
+			in:   inputYAML,
+			cfg: action.Config{
+				Type:  "setYAML",
+				Key:   "services.api.image",
+				Value: "hype/api:${VERSION}",
+				Path:  "set_existing.yml",
+			},
+			vars:        map[string]string{"VERSION": "2.0"},
+			wantMsg:     "Set `services.api.image` to `\"hype/api:2.0\"` in `set_existing.yml`",
+			wantMatches: 1,
+			out: `# Services for the hype stack.
+services:
+    api:
+        image: "hype/api:2.0" # pinned
+        ports:
+            - 8080
+    # The worker processes jobs.
+    worker:
+        image: hype/worker:1.0
+`,
+		},
+		{
+			name: "set sequence item",
+			in:   inputYAML,
+			cfg: action.Config{
+				Type:  "setYAML",
+				Key:   "services.api.ports.0",
+				Value: 9090,
+				Path:  "set_sequence.yml",
+			},
+			wantMsg:     "Set `services.api.ports.0` to `9090` in `set_sequence.yml`",
+			wantMatches: 1,
+			out: `# Services for the hype stack.
+services:
+    api:
+        image: "hype/api:1.0" # pinned
+        ports:
+            - 9090
+    # The worker processes jobs.
+    worker:
+        image: hype/worker:1.0
+`,
+		},
+		{
+			name: "add missing key",
+			in:   inputYAML,
+			cfg: action.Config{
+				Type:  "setYAML",
+				Key:   "services.worker.environment.QUEUE",
+				Value: "hype",
+				Path:  "add_key.yml",
+			},
+			wantMsg:     "Added `services.worker.environment.QUEUE` with value `\"hype\"` to `add_key.yml`",
+			wantMatches: 0,
+			out: `# Services for the hype stack.
+services:
+    api:
+        image: "hype/api:1.0" # pinned
+        ports:
+            - 8080
+    # The worker processes jobs.
+    worker:
+        image: hype/worker:1.0
+        environment:
+            QUEUE: hype
+`,
+		},
+		{
+			name: "set unchanged",
+			in:   inputYAML,
+			cfg: action.Config{
+				Type:  "setYAML",
+				Key:   "services.worker.image",
+				Value: "hype/worker:1.0",
+				Path:  "set_unchanged.yml",
+			},
+			wantMsg:     "Set `services.worker.image` to `\"hype/worker:1.0\"` in `set_unchanged.yml`",
+			wantMatches: 1,
+			out:         inputYAML,
+		},
+		{
+			name: "delete key",
+			in:   inputYAML,
+			cfg: action.Config{
+				Type: "deleteYAML",
+				Key:  "services.api.ports",
+				Path: "delete_key.yml",
+			},
+			wantMsg:     "Deleted `services.api.ports` from `delete_key.yml`",
+			wantMatches: 1,
+			out: `# Services for the hype stack.
+services:
+    api:
+        image: "hype/api:1.0" # pinned
+    # The worker processes jobs.
+    worker:
+        image: hype/worker:1.0
+`,
+		},
+		{
+			name: "delete missing key",
+			in:   inputYAML,
+			cfg: action.Config{
+				Type: "deleteYAML",
+				Key:  "services.db",
+				Path: "delete_missing.yml",
+			},
+			wantMsg:     "Key `services.db` not found in `delete_missing.yml`",
+			wantMatches: 0,
+			out:         inputYAML,
+		},
+		{
+			name: "multiple documents",
+			in:   "kind: Deployment\nreplicas: 1\n---\nkind: Service\n",
+			cfg: action.Config{
+				Type:  "setYAML",
+				Key:   "replicas",
+				Value: 3,
+				Path:  "multiple_documents.yml",
+			},
+			wantMsg:     "Set `replicas` to `3` in `multiple_documents.yml`",
+			wantMatches: 1,
+			out:         "kind: Deployment\nreplicas: 3\n---\nkind: Service\n",
+		},
+		{
+			name:        "keep formatting when setting",
+			in:          inputYAMLFormatted,
+			cfg:         action.Config{Type: "setYAML", Key: "steps.1.run", Value: "make ci", Path: "formatted_set.yml"},
+			wantMsg:     "Set `steps.1.run` to `\"make ci\"` in `formatted_set.yml`",
+			wantMatches: 1,
+			out: `name: hype
+
+steps:
+- run: make build
+- run: make ci
+
+env: {GO: "1.19", CGO_ENABLED: 0}
+
+cache:
+`,
+		},
+		{
+			name:        "keep formatting when adding",
+			in:          inputYAMLFormatted,
+			cfg:         action.Config{Type: "setYAML", Key: "steps.0.name", Value: "Build", Path: "formatted_add.yml"},
+			wantMsg:     "Added `steps.0.name` with value `\"Build\"` to `formatted_add.yml`",
+			wantMatches: 0,
+			out: `name: hype
+
+steps:
+- run: make build
+  name: Build
+- run: |
+    make test
+    make lint
+
+env: {GO: "1.19", CGO_ENABLED: 0}
+
+cache:
+`,
+		},
+		{
+			name:        "keep formatting when deleting",
+			in:          inputYAMLFormatted,
+			cfg:         action.Config{Type: "deleteYAML", Key: "steps.0", Path: "formatted_delete.yml"},
+			wantMsg:     "Deleted `steps.0` from `formatted_delete.yml`",
+			wantMatches: 1,
+			out: `name: hype
+
+steps:
+- run: |
+    make test
+    make lint
+
+env: {GO: "1.19", CGO_ENABLED: 0}
+
+cache:
+`,
+		},
+		{
+			name:        "replace non-indented list",
+			in:          inputYAMLFormatted,
+			cfg:         action.Config{Type: "setYAML", Key: "steps", Value: []any{map[string]any{"run": "make"}}, Path: "formatted_list.yml"},
+			wantMsg:     "Set `steps` to `[{\"run\":\"make\"}]` in `formatted_list.yml`",
+			wantMatches: 1,
+			out: `name: hype
+
+steps:
+- run: make
+
+env: {GO: "1.19", CGO_ENABLED: 0}
+
+cache:
+`,
+		},
+		{
+			name:        "set in flow mapping",
+			in:          inputYAMLFormatted,
+			cfg:         action.Config{Type: "setYAML", Key: "env.GO", Value: "1.20", Path: "formatted_flow.yml"},
+			wantMsg:     "Set `env.GO` to `\"1.20\"` in `formatted_flow.yml`",
+			wantMatches: 1,
+			out: `name: hype
+
+steps:
+- run: make build
+- run: |
+    make test
+    make lint
+
+env: {GO: "1.20", CGO_ENABLED: 0}
+
+cache:
+`,
+		},
+		{
+			name:        "set empty value",
+			in:          inputYAMLFormatted,
+			cfg:         action.Config{Type: "setYAML", Key: "cache", Value: []any{"~/go/pkg/mod"}, Path: "formatted_empty.yml"},
+			wantMsg:     "Set `cache` to `[\"~/go/pkg/mod\"]` in `formatted_empty.yml`",
+			wantMatches: 1,
+			out: `name: hype
+
+steps:
+- run: make build
+- run: |
+    make test
+    make lint
+
+env: {GO: "1.19", CGO_ENABLED: 0}
+
+cache: [~/go/pkg/mod]
+`,
+		},
+		{
+			name:        "delete only key",
+			in:          "services:\n  api:\n    image: hype/api:1.0\n\nvolumes: {}\n",
+			cfg:         action.Config{Type: "deleteYAML", Key: "services.api.image", Path: "delete_only.yml"},
+			wantMsg:     "Deleted `services.api.image` from `delete_only.yml`",
+			wantMatches: 1,
+			out:         "services:\n  api: {}\n\nvolumes: {}\n",
+		},
+		{
+			name:        "add key to empty file",
+			in:          "# Nothing yet.\n",
+			cfg:         action.Config{Type: "setYAML", Key: "services.api.image", Value: "hype/api:1.0", Path: "empty.yml"},
+			wantMsg:     "Added `services.api.image` with value `\"hype/api:1.0\"` to `empty.yml`",
+			wantMatches: 0,
+			out:         "# Nothing yet.\nservices:\n  api:\n    image: hype/api:1.0\n",
+		},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(td, tt.cfg.Path)
+			if err := os.WriteFile(path, []byte(tt.in), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res, err := a.Run(context.Background(), pathTarget(td), action.Arguments{Variables: tt.vars})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Message != tt.wantMsg {
+				t.Errorf("got message\n\t%s\nwant\n\t%s", res.Message, tt.wantMsg)
+			}
+			if res.Matches != tt.wantMatches {
+				t.Errorf("got %d matches, want %d", res.Matches, tt.wantMatches)
+			}
+			if wantChanged := tt.in != tt.out; res.Changed != wantChanged {
+				t.Errorf("got changed %t, want %t", res.Changed, wantChanged)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if got := string(data); got != tt.out {
+				t.Errorf("got file\n\t%s\nwant\n\t%s", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestYAMLActionError(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		cfg  action.Config
+	}{
+		{
+			name: "invalid YAML",
+			in:   "services: [api\n",
+			cfg:  action.Config{Type: "setYAML", Key: "services", Value: "hype", Path: "invalid.yml"},
+		},
+		{
+			name: "required key missing",
+			in:   inputYAML,
+			cfg:  action.Config{Type: "deleteYAML", Key: "services.db", Path: "required.yml", Required: true},
+		},
+		{
+			name: "key through scalar",
+			in:   inputYAML,
+			cfg:  action.Config{Type: "setYAML", Key: "services.api.image.tag", Value: "2.0", Path: "scalar.yml"},
+		},
+		{
+			name: "index out of range",
+			in:   inputYAML,
+			cfg:  action.Config{Type: "setYAML", Key: "services.api.ports.3", Value: 9090, Path: "index.yml"},
+		},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(td, tt.cfg.Path)
+			if err := os.WriteFile(path, []byte(tt.in), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			a, err := action.Parse(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			_, err = a.Run(context.Background(), pathTarget(td), action.Arguments{})
+			if err == nil {
+				t.Error("want non-nil error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// bitbucket is a Provider for Bitbucket Server and Bitbucket Data Center.
+// Repos are identified by a project key and repo slug, i.e. PROJECT/repo.
+type bitbucket struct {
+	host Host
+}
+
+func (bb bitbucket) CloneURL(repo string) (string, error) {
+	if _, _, err := bb.split(repo); err != nil {
+		return "", err
+	}
+	return bb.host.cloneURL(repo)
+}
+
+func (bb bitbucket) CreatePRURL(pr PullRequest) string {
+	project, slug, _ := bb.split(pr.Repo)
+	q := make(url.Values)
+	q.Set("sourceBranch", "refs/heads/"+pr.Head)
+	if pr.Base != "" {
+		q.Set("targetBranch", "refs/heads/"+pr.Base)
+	}
+	// The create param has no value and must come first.
+	return bb.host.webURL("/projects/%s/repos/%s/pull-requests?create&%s", project, slug, q.Encode())
+}
+
+func (bb bitbucket) GitAuth(ctx context.Context) (transport.AuthMethod, error) {
+	token := os.Getenv("BITBUCKET_TOKEN")
+	if token == "" {
+		return nil, nil
+	}
+	// HTTP access tokens are sent as bearer tokens, same as for the API.
+	return &githttp.TokenAuth{Token: token}, nil
+}
+
+func (bb bitbucket) CreatePR(ctx context.Context, pr PullRequest) (string, error) {
+	if err := pr.unsupported("Bitbucket", "labels", "teamReviewers", "assignees"); err != nil {
+		return "", err
+	}
+	project, slug, err := bb.split(pr.Repo)
+	if err != nil {
+		return "", err
+	}
+	reviewers := make([]map[string]any, len(pr.Reviewers))
+	for i, r := range pr.Reviewers {
+		reviewers[i] = map[string]any{"user": map[string]string{"name": r}}
+	}
+	repoRef := map[string]any{
+		"slug":    slug,
+		"project": map[string]string{"key": project},
+	}
+	body := map[string]any{
+		"title":       pr.Title,
+		"description": pr.Body,
+		"fromRef":     map[string]any{"id": "refs/heads/" + pr.Head, "repository": repoRef},
+		"toRef":       map[string]any{"id": "refs/heads/" + pr.Base, "repository": repoRef},
+		"reviewers":   reviewers,
+	}
+	if pr.Draft {
+		// Only set if needed since older versions of Bitbucket don't support drafts.
+		body["draft"] = true
+	}
+	endpoint := bb.host.apiURL("/projects/%s/repos/%s/pull-requests", project, slug)
+
+	q := make(url.Values)
+	q.Set("at", "refs/heads/"+pr.Head)
+	q.Set("direction", "OUTGOING")
+	q.Set("state", "OPEN")
+	var existing struct {
+		Values []bitbucketPR `json:"values"`
+	}
+	err = bb.request(http.MethodGet, endpoint+"?"+q.Encode(), nil, http.StatusOK).do(ctx, &existing)
+	if err != nil {
+		return "", fmt.Errorf("unable to get existing PRs for repo %s: %w", pr.Repo, err)
+	}
+	for _, e := range existing.Values {
+		if e.ToRef.ID != "refs/heads/"+pr.Base {
+			continue
+		}
+		// The version must match the current one to prevent conflicting updates.
+		update := map[string]any{
+			"version":     e.Version,
+			"title":       pr.Title,
+			"description": pr.Body,
+		}
+		if len(pr.Reviewers) > 0 {
+			update["reviewers"] = reviewers
+		}
+		err := bb.request(http.MethodPut, fmt.Sprintf("%s/%d", endpoint, e.ID), update, http.StatusOK).do(ctx, nil)
+		if err != nil {
+			return e.url(), fmt.Errorf("unable to update PR %s: %w", e.url(), err)
+		}
+		return e.url(), nil
+	}
+
+	var rb bitbucketPR
+	err = bb.request(http.MethodPost, endpoint, body, http.StatusCreated).do(ctx, &rb)
+	if err != nil {
+		return "", fmt.Errorf("unable to create PR for repo %s: %w", pr.Repo, err)
+	}
+	if rb.url() == "" {
+		return "", fmt.Errorf("no PR URL in response from Bitbucket API for repo %s", pr.Repo)
+	}
+	return rb.url(), nil
+}
+
+// bitbucketPR is a pull request returned by the Bitbucket API.
+type bitbucketPR struct {
+	ID      int `json:"id"`
+	Version int `json:"version"`
+	ToRef   struct {
+		ID string `json:"id"`
+	} `json:"toRef"`
+	Links struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+// url returns the URL of the PR's web page.
+func (pr bitbucketPR) url() string {
+	if len(pr.Links.Self) == 0 {
+		return ""
+	}
+	return pr.Links.Self[0].Href
+}
+
+// split splits the name of a repo into the project key and repo slug.
+func (bb bitbucket) split(repo string) (project, slug string, err error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid Bitbucket repo %s, must be of the form PROJECT/repo", repo)
+	}
+	return parts[0], parts[1], nil
+}
+
+func (bb bitbucket) request(method, endpoint string, body any, wantStatus int) apiRequest {
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+os.Getenv("BITBUCKET_TOKEN"))
+	header.Set("Accept", "application/json")
+	return apiRequest{
+		name:       "Bitbucket",
+		method:     method,
+		url:        endpoint,
+		header:     header,
+		body:       body,
+		wantStatus: wantStatus,
+	}
+}
@@ -0,0 +1,98 @@
+package git_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TouchBistro/cannon/git"
+)
+
+func TestBitbucketCreatePR(t *testing.T) {
+	type ref struct {
+		ID         string `json:"id"`
+		Repository struct {
+			Slug    string `json:"slug"`
+			Project struct {
+				Key string `json:"key"`
+			} `json:"project"`
+		} `json:"repository"`
+	}
+	var gotBody struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		FromRef     ref    `json:"fromRef"`
+		ToRef       ref    `json:"toRef"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/rest/api/1.0/projects/PLAT/repos/hype/pull-requests" {
+			// No existing PRs.
+			_, _ = w.Write([]byte(`{"values": []}`))
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/rest/api/1.0/projects/PLAT/repos/hype/pull-requests" {
+			t.Errorf("got unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer hype-token" {
+			t.Errorf("got Authorization header %q, want %q", got, "Bearer hype-token")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 1, "links": {"self": [{"href": "https://bitbucket.example.com/projects/PLAT/repos/hype/pull-requests/1"}]}}`))
+	}))
+	defer srv.Close()
+	t.Setenv("BITBUCKET_TOKEN", "hype-token")
+
+	repo := prepareRepo(t, "PLAT/hype", git.Host{
+		Type:   git.HostTypeBitbucket,
+		APIURL: srv.URL + "/rest/api/1.0",
+		WebURL: "https://bitbucket.example.com",
+	})
+	prURL, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test", Body: "Hype changes"})
+	if err != nil {
+		t.Fatalf("failed to create pull request: %v", err)
+	}
+	if want := "https://bitbucket.example.com/projects/PLAT/repos/hype/pull-requests/1"; prURL != want {
+		t.Errorf("got pull request URL %s, want %s", prURL, want)
+	}
+	if gotBody.Title != "cannon/test" || gotBody.Description != "Hype changes" {
+		t.Errorf("got title %q and description %q, want %q and %q", gotBody.Title, gotBody.Description, "cannon/test", "Hype changes")
+	}
+	for _, tt := range []struct {
+		name string
+		ref  ref
+		want string
+	}{
+		{"fromRef", gotBody.FromRef, "refs/heads/cannon/test"},
+		{"toRef", gotBody.ToRef, "refs/heads/master"},
+	} {
+		if tt.ref.ID != tt.want {
+			t.Errorf("got %s id %q, want %q", tt.name, tt.ref.ID, tt.want)
+		}
+		if tt.ref.Repository.Slug != "hype" || tt.ref.Repository.Project.Key != "PLAT" {
+			t.Errorf("got %s repository %s/%s, want PLAT/hype", tt.name, tt.ref.Repository.Project.Key, tt.ref.Repository.Slug)
+		}
+	}
+
+	wantURL := "https://bitbucket.example.com/projects/PLAT/repos/hype/pull-requests?create&sourceBranch=refs%2Fheads%2Fcannon%2Ftest&targetBranch=refs%2Fheads%2Fmaster"
+	if got := repo.CreatePRURL("cannon/test"); got != wantURL {
+		t.Errorf("got create pull request URL %s, want %s", got, wantURL)
+	}
+}
+
+func TestBitbucketInvalidRepo(t *testing.T) {
+	host := git.Host{
+		Type:     git.HostTypeBitbucket,
+		CloneURL: "ssh://git@bitbucket.example.com:7999/${REPO}.git",
+	}
+	_, err := git.Prepare(context.Background(), "touchbistro/platform/hype", t.TempDir(), "master", host)
+	if err == nil {
+		t.Error("want error for repo that is not of the form PROJECT/repo")
+	}
+}
@@ -3,11 +3,8 @@ package git
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -16,8 +13,10 @@ import (
 	"github.com/TouchBistro/goutils/file"
 	"github.com/TouchBistro/goutils/progress"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 // Repository holds the state of a Git repository.
@@ -25,6 +24,7 @@ type Repository struct {
 	name       string
 	path       string
 	baseBranch string
+	provider   Provider
 	r          *git.Repository
 	w          *git.Worktree
 }
@@ -42,20 +42,30 @@ func (repo *Repository) Path() string {
 // Prepare prepares the repo for use and returns a Repository instance.
 // If the repo does not exist, it will be cloned to dir. Otherwise, any
 // uncommitted changes will be discarded and the base branch will be updated.
-func Prepare(ctx context.Context, name, dir, baseBranch string) (*Repository, error) {
+func Prepare(ctx context.Context, name, dir, baseBranch string, host Host) (*Repository, error) {
 	tracker := progress.TrackerFromContext(ctx)
 	path := filepath.Join(dir, name)
 	repo := &Repository{name: name, path: path, baseBranch: baseBranch}
 	skipCleanup := false
 	var err error
+	repo.provider, err = host.provider()
+	if err != nil {
+		return nil, err
+	}
 	if !file.Exists(path) {
 		// If the repo doesn't exist all we need to do is clone it.
 		// Don't need to worry about any dirty state.
 		skipCleanup = true
 		tracker.Debugf("Repo %s does not exist, cloning", name)
-		repo.r, err = git.PlainCloneContext(ctx, path, false, &git.CloneOptions{
-			URL: fmt.Sprintf("git@github.com:%s.git", name),
-		})
+		url, err := repo.provider.CloneURL(name)
+		if err != nil {
+			return nil, err
+		}
+		auth, err := repo.authForURL(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		repo.r, err = git.PlainCloneContext(ctx, path, false, &git.CloneOptions{URL: url, Auth: auth})
 		if err != nil {
 			return nil, fmt.Errorf("failed to clone %s to %s: %w", name, dir, err)
 		}
@@ -65,6 +75,11 @@ func Prepare(ctx context.Context, name, dir, baseBranch string) (*Repository, er
 		if err != nil {
 			return nil, fmt.Errorf("failed to open repo at path %s: %w", path, err)
 		}
+		// Make sure the remote matches the host config in case it changed,
+		// e.g. switching from the ssh to the https transport.
+		if err := repo.setRemoteURL(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Get worktree now and save it since most operations require it.
@@ -110,7 +125,11 @@ func Prepare(ctx context.Context, name, dir, baseBranch string) (*Repository, er
 	}
 
 	// Update branch.
-	err = repo.w.PullContext(ctx, &git.PullOptions{SingleBranch: true})
+	auth, err := repo.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = repo.w.PullContext(ctx, &git.PullOptions{SingleBranch: true, Auth: auth})
 	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
 		return nil, fmt.Errorf("failed to pull changes from remote for repo %s: %w", name, err)
 	}
@@ -118,13 +137,50 @@ func Prepare(ctx context.Context, name, dir, baseBranch string) (*Repository, er
 	return repo, nil
 }
 
-// CreateBranch creates a new branch and switches to it.
+// Open opens a repo that was previously cloned to dir by Prepare and returns a Repository instance.
+// Unlike Prepare, the repo is left as is, no changes are discarded and the base branch is not updated.
+func Open(name, dir, baseBranch string, host Host) (*Repository, error) {
+	path := filepath.Join(dir, name)
+	repo := &Repository{name: name, path: path, baseBranch: baseBranch}
+	var err error
+	repo.provider, err = host.provider()
+	if err != nil {
+		return nil, err
+	}
+	repo.r, err = git.PlainOpen(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repo at path %s: %w", path, err)
+	}
+	repo.w, err = repo.r.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree for repo %s: %w", name, err)
+	}
+	return repo, nil
+}
+
+// CheckoutBranch switches to an existing branch.
+func (repo *Repository) CheckoutBranch(branch string) error {
+	err := repo.w.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to checkout branch %s in repo %s: %w", branch, repo.name, err)
+	}
+	return nil
+}
+
+// CreateBranch creates a new branch from HEAD and switches to it.
+// If the branch already exists, it is rebuilt from HEAD.
 func (repo *Repository) CreateBranch(branch string) error {
 	headRef, err := repo.r.Head()
 	if err != nil {
 		return fmt.Errorf("failed to get HEAD for repo %s: %w", repo.name, err)
 	}
 	branchRef := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), headRef.Hash())
+	err = repo.r.Storer.RemoveReference(branchRef.Name())
+	if err != nil {
+		return fmt.Errorf("failed to delete existing branch %s in repo %s: %w", branch, repo.name, err)
+	}
 	err = repo.w.Checkout(&git.CheckoutOptions{
 		Hash:   branchRef.Hash(),
 		Branch: branchRef.Name(),
@@ -136,20 +192,48 @@ func (repo *Repository) CreateBranch(branch string) error {
 	return nil
 }
 
+// FetchBranch updates the remote-tracking branch for branch so that
+// it matches the remote. If the branch does not exist on the remote,
+// any stale remote-tracking branch is deleted.
+//
+// This should be called before rebuilding a branch that may already exist
+// on the remote with CreateBranch, so that Push knows what it is replacing.
+func (repo *Repository) FetchBranch(ctx context.Context, branch string) error {
+	remoteRef := plumbing.NewRemoteReferenceName("origin", branch)
+	refSpec := config.RefSpec(fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(branch), remoteRef))
+	auth, err := repo.auth(ctx)
+	if err != nil {
+		return err
+	}
+	err = repo.r.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{refSpec},
+		Auth:       auth,
+	})
+	if errors.Is(err, git.NoMatchingRefSpecError{}) {
+		if err := repo.r.Storer.RemoveReference(remoteRef); err != nil {
+			return fmt.Errorf("failed to delete remote-tracking branch %s in repo %s: %w", remoteRef.Short(), repo.name, err)
+		}
+		return nil
+	}
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to fetch branch %s in repo %s: %w", branch, repo.name, err)
+	}
+	return nil
+}
+
 // CommitChanges will stage all changes and commit them.
-func (repo *Repository) CommitChanges(ctx context.Context, msg string) error {
-	// Shell out to git add since there were issues trying to do it will the git module.
-	var stderr bytes.Buffer
-	cmd := command.New(command.WithDir(repo.path), command.WithStderr(&stderr))
-	if err := cmd.Exec(ctx, "git", "add", "."); err != nil {
-		return fmt.Errorf("failed to stage changes: %s: %w", stderr.String(), err)
+// It returns the hash of the created commit.
+func (repo *Repository) CommitChanges(ctx context.Context, msg string) (string, error) {
+	if err := repo.stageChanges(ctx); err != nil {
+		return "", err
 	}
 
 	username, email, err := user(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
-	_, err = repo.w.Commit(msg, &git.CommitOptions{
+	hash, err := repo.w.Commit(msg, &git.CommitOptions{
 		Author: &object.Signature{
 			Name:  username,
 			Email: email,
@@ -157,19 +241,150 @@ func (repo *Repository) CommitChanges(ctx context.Context, msg string) error {
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to commit changes in repo %s: %w", repo.name, err)
+		return "", fmt.Errorf("failed to commit changes in repo %s: %w", repo.name, err)
+	}
+	return hash.String(), nil
+}
+
+// HasChanges reports whether the worktree has any staged, unstaged or untracked changes.
+func (repo *Repository) HasChanges(ctx context.Context) (bool, error) {
+	// Shell out to git status for consistency with how changes are staged.
+	var stdout, stderr bytes.Buffer
+	cmd := command.New(command.WithDir(repo.path), command.WithStdout(&stdout), command.WithStderr(&stderr))
+	if err := cmd.Exec(ctx, "git", "status", "--porcelain"); err != nil {
+		return false, fmt.Errorf("failed to get status of repo %s: %s: %w", repo.name, stderr.String(), err)
+	}
+	return strings.TrimSpace(stdout.String()) != "", nil
+}
+
+// Diff will stage all changes and return a unified diff of them against HEAD.
+// If color is true, the diff will contain ANSI color escape sequences.
+func (repo *Repository) Diff(ctx context.Context, color bool) (string, error) {
+	if err := repo.stageChanges(ctx); err != nil {
+		return "", err
+	}
+	colorFlag := "--color=never"
+	if color {
+		colorFlag = "--color=always"
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := command.New(command.WithDir(repo.path), command.WithStdout(&stdout), command.WithStderr(&stderr))
+	if err := cmd.Exec(ctx, "git", "diff", "--cached", colorFlag); err != nil {
+		return "", fmt.Errorf("failed to diff changes in repo %s: %s: %w", repo.name, stderr.String(), err)
+	}
+	return stdout.String(), nil
+}
+
+// Reset discards all staged and unstaged changes, including untracked files,
+// so that the worktree matches HEAD.
+func (repo *Repository) Reset() error {
+	headRef, err := repo.r.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get HEAD for repo %s: %w", repo.name, err)
+	}
+	err = repo.w.Reset(&git.ResetOptions{Commit: headRef.Hash(), Mode: git.HardReset})
+	if err != nil {
+		return fmt.Errorf("failed to reset repo %s: %w", repo.name, err)
+	}
+	err = repo.w.Clean(&git.CleanOptions{Dir: true})
+	if err != nil {
+		return fmt.Errorf("failed to clean repo %s: %w", repo.name, err)
+	}
+	return nil
+}
+
+// stageChanges stages all changes in the worktree.
+func (repo *Repository) stageChanges(ctx context.Context) error {
+	// Shell out to git add since there were issues trying to do it will the git module.
+	var stderr bytes.Buffer
+	cmd := command.New(command.WithDir(repo.path), command.WithStderr(&stderr))
+	if err := cmd.Exec(ctx, "git", "add", "."); err != nil {
+		return fmt.Errorf("failed to stage changes: %s: %w", stderr.String(), err)
 	}
 	return nil
 }
 
+// Push pushes the current branch to the remote.
+//
+// If the branch already exists on the remote, it is force pushed with a lease.
+// This allows a branch that was fetched with FetchBranch and rebuilt by CreateBranch to replace the remote one,
+// while never overwriting changes that were pushed by someone else since then.
 func (repo *Repository) Push(ctx context.Context) error {
-	err := repo.r.PushContext(ctx, &git.PushOptions{RemoteName: "origin"})
+	headRef, err := repo.r.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get HEAD for repo %s: %w", repo.name, err)
+	}
+	branch := headRef.Name()
+	auth, err := repo.auth(ctx)
 	if err != nil {
+		return err
+	}
+	opts := &git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec(branch + ":" + branch)},
+		Auth:       auth,
+	}
+	// The lease needs the remote-tracking branch to know what the remote had.
+	// If there isn't one, the branch is new and a regular push will fail if that changed.
+	_, err = repo.r.Reference(plumbing.NewRemoteReferenceName("origin", branch.Short()), false)
+	if err == nil {
+		opts.ForceWithLease = &git.ForceWithLease{RefName: branch}
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return fmt.Errorf("failed to get remote-tracking branch for %s in repo %s: %w", branch.Short(), repo.name, err)
+	}
+	err = repo.r.PushContext(ctx, opts)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
 		return fmt.Errorf("failed to push to remote in repo %s: %w", repo.name, err)
 	}
 	return nil
 }
 
+// setRemoteURL sets the URL of the origin remote to the clone URL from the provider.
+func (repo *Repository) setRemoteURL() error {
+	url, err := repo.provider.CloneURL(repo.name)
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.r.Config()
+	if err != nil {
+		return fmt.Errorf("failed to get config for repo %s: %w", repo.name, err)
+	}
+	remote, ok := cfg.Remotes["origin"]
+	if !ok {
+		return fmt.Errorf("repo %s has no origin remote", repo.name)
+	}
+	if len(remote.URLs) == 1 && remote.URLs[0] == url {
+		return nil
+	}
+	remote.URLs = []string{url}
+	if err := repo.r.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to set origin URL for repo %s: %w", repo.name, err)
+	}
+	return nil
+}
+
+// auth returns the auth to use for operations on the origin remote.
+func (repo *Repository) auth(ctx context.Context) (transport.AuthMethod, error) {
+	remote, err := repo.r.Remote("origin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get origin remote for repo %s: %w", repo.name, err)
+	}
+	return repo.authForURL(ctx, remote.Config().URLs[0])
+}
+
+// authForURL returns the auth to use for operations on the remote with the given URL.
+// The provider's auth is only used for HTTPS, SSH uses the SSH agent.
+func (repo *Repository) authForURL(ctx context.Context, url string) (transport.AuthMethod, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, nil
+	}
+	auth, err := repo.provider.GitAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials for repo %s: %w", repo.name, err)
+	}
+	return auth, nil
+}
+
 // user gets the current configured git user.
 func user(ctx context.Context) (username, email string, err error) {
 	var outbuf, errbuf bytes.Buffer
@@ -192,49 +407,26 @@ func user(ctx context.Context) (username, email string, err error) {
 	return
 }
 
-// GitHub support
-
-func CreatePRURL(repo, branch string) string {
-	return fmt.Sprintf("https://github.com/%s/pull/new/%s", repo, branch)
+// CreatePRURL returns the URL of the web page to create a PR for branch.
+func (repo *Repository) CreatePRURL(branch string) string {
+	return repo.provider.CreatePRURL(repo.pullRequest(PullRequest{Head: branch}))
 }
 
-func (repo *Repository) CreatePR(ctx context.Context, branch, desc string) (string, error) {
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(map[string]string{
-		"title": branch,
-		"head":  branch,
-		"base":  repo.baseBranch,
-		"body":  desc,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to create JSON body for request: %w", err)
-	}
-
-	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls", repo.name)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
-	if err != nil {
-		return "", fmt.Errorf("failed to create POST request to GitHub API: %w", err)
-	}
-	token := fmt.Sprintf("token %s", os.Getenv("GITHUB_TOKEN"))
-	req.Header.Add("Authorization", token)
-	// Use v3 API
-	req.Header.Add("Accept", "application/vnd.github.v3+json")
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("unable to create PR for repo %s: %w", repo.name, err)
-	}
-	defer res.Body.Close()
-	if res.StatusCode != 201 {
-		return "", fmt.Errorf("got %d response from GitHub API", res.StatusCode)
-	}
+// CreatePR creates a PR to merge pr.Head into the base branch and returns the URL of the PR.
+// The Repo and Base fields of pr are set from the repository. If pr.Title is empty, the
+// name of the branch is used.
+//
+// If the PR was created but updating it afterwards failed, e.g. adding labels,
+// both the URL and an error are returned.
+func (repo *Repository) CreatePR(ctx context.Context, pr PullRequest) (string, error) {
+	return repo.provider.CreatePR(ctx, repo.pullRequest(pr))
+}
 
-	var rb struct {
-		HTMLURL string `json:"html_url"`
-	}
-	err = json.NewDecoder(res.Body).Decode(&rb)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode JSON from reponse body: %w", err)
+func (repo *Repository) pullRequest(pr PullRequest) PullRequest {
+	pr.Repo = repo.name
+	pr.Base = repo.baseBranch
+	if pr.Title == "" {
+		pr.Title = pr.Head
 	}
-	return rb.HTMLURL, nil
+	return pr
 }
@@ -0,0 +1,236 @@
+package git_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TouchBistro/cannon/git"
+)
+
+// setupGitConfig creates a global git config with a user so that commits can be made.
+func setupGitConfig(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	gitconfig := filepath.Join(home, ".gitconfig")
+	data := "[user]\n\tname = Cannon Test\n\temail = cannon@example.com\n"
+	if err := os.WriteFile(gitconfig, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write git config: %v", err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+}
+
+// runGit runs a git command in dir and fails the test if it fails.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s: %v", strings.Join(args, " "), out, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// setupRemote creates a bare repo at remoteDir/name.git with a single commit on master.
+func setupRemote(t *testing.T, remoteDir, name string) {
+	t.Helper()
+	src := t.TempDir()
+	runGit(t, src, "init", "-b", "master")
+	if err := os.WriteFile(filepath.Join(src, "README.md"), []byte("# Hype\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	runGit(t, src, "add", ".")
+	runGit(t, src, "commit", "-m", "Initial commit")
+	runGit(t, src, "clone", "--bare", src, filepath.Join(remoteDir, name+".git"))
+}
+
+// prepareRepo creates a remote repo and prepares it using host.
+// The clone URL of host is set to point to the remote repo.
+func prepareRepo(t *testing.T, name string, host git.Host) *git.Repository {
+	t.Helper()
+	setupGitConfig(t)
+	remoteDir := t.TempDir()
+	setupRemote(t, remoteDir, name)
+	host.CloneURL = "file://" + filepath.ToSlash(remoteDir) + "/${REPO}.git"
+	repo, err := git.Prepare(context.Background(), name, t.TempDir(), "master", host)
+	if err != nil {
+		t.Fatalf("failed to prepare repo: %v", err)
+	}
+	return repo
+}
+
+func TestPrepareCommitPush(t *testing.T) {
+	setupGitConfig(t)
+	remoteDir := t.TempDir()
+	setupRemote(t, remoteDir, "TouchBistro/hype")
+	host := git.Host{CloneURL: "file://" + filepath.ToSlash(remoteDir) + "/${REPO_OWNER}/${REPO_NAME}.git"}
+
+	ctx := context.Background()
+	repo, err := git.Prepare(ctx, "TouchBistro/hype", t.TempDir(), "master", host)
+	if err != nil {
+		t.Fatalf("failed to prepare repo: %v", err)
+	}
+	if err := repo.CreateBranch("cannon/test"); err != nil {
+		t.Fatalf("failed to create branch: %v", err)
+	}
+
+	hasChanges, err := repo.HasChanges(ctx)
+	if err != nil {
+		t.Fatalf("failed to check for changes: %v", err)
+	}
+	if hasChanges {
+		t.Error("want no changes in freshly prepared repo")
+	}
+	if err := os.WriteFile(filepath.Join(repo.Path(), "hype.txt"), []byte("hype\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	hasChanges, err = repo.HasChanges(ctx)
+	if err != nil {
+		t.Fatalf("failed to check for changes: %v", err)
+	}
+	if !hasChanges {
+		t.Error("want changes after writing a file")
+	}
+
+	hash, err := repo.CommitChanges(ctx, "Add hype")
+	if err != nil {
+		t.Fatalf("failed to commit changes: %v", err)
+	}
+	if err := repo.Push(ctx); err != nil {
+		t.Fatalf("failed to push changes: %v", err)
+	}
+	got := runGit(t, filepath.Join(remoteDir, "TouchBistro/hype.git"), "rev-parse", "cannon/test")
+	if got != hash {
+		t.Errorf("got remote branch at %s, want %s", got, hash)
+	}
+}
+
+func TestRebuildBranchForcePush(t *testing.T) {
+	setupGitConfig(t)
+	remoteDir := t.TempDir()
+	setupRemote(t, remoteDir, "TouchBistro/hype")
+	remotePath := filepath.Join(remoteDir, "TouchBistro/hype.git")
+	host := git.Host{CloneURL: "file://" + filepath.ToSlash(remoteDir) + "/${REPO}.git"}
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// commitAndPush prepares the repo, rebuilds the branch and commits a new file to it.
+	// It returns the hash of the commit.
+	commitAndPush := func(file string, interfere bool) (string, error) {
+		t.Helper()
+		repo, err := git.Prepare(ctx, "TouchBistro/hype", dir, "master", host)
+		if err != nil {
+			t.Fatalf("failed to prepare repo: %v", err)
+		}
+		if err := repo.FetchBranch(ctx, "cannon/stable"); err != nil {
+			t.Fatalf("failed to fetch branch: %v", err)
+		}
+		if err := repo.CreateBranch("cannon/stable"); err != nil {
+			t.Fatalf("failed to create branch: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repo.Path(), file), []byte("hype\n"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		hash, err := repo.CommitChanges(ctx, "Add "+file)
+		if err != nil {
+			t.Fatalf("failed to commit changes: %v", err)
+		}
+		if interfere {
+			// Simulate someone else pushing to the branch after it was fetched.
+			runGit(t, remotePath, "update-ref", "refs/heads/cannon/stable", "master")
+		}
+		return hash, repo.Push(ctx)
+	}
+
+	if _, err := commitAndPush("first.txt", false); err != nil {
+		t.Fatalf("failed to push new branch: %v", err)
+	}
+	hash, err := commitAndPush("second.txt", false)
+	if err != nil {
+		t.Fatalf("failed to push rebuilt branch: %v", err)
+	}
+	if got := runGit(t, remotePath, "rev-parse", "cannon/stable"); got != hash {
+		t.Errorf("got remote branch at %s, want %s", got, hash)
+	}
+	// The rebuilt branch must not contain the first commit.
+	if got := runGit(t, remotePath, "ls-tree", "--name-only", "cannon/stable"); strings.Contains(got, "first.txt") {
+		t.Errorf("got files %q on rebuilt branch, want no first.txt", got)
+	}
+
+	if _, err := commitAndPush("third.txt", true); err == nil {
+		t.Error("want error when the remote branch changed since it was fetched")
+	}
+}
+
+func TestHTTPSTransport(t *testing.T) {
+	setupGitConfig(t)
+	remoteDir := t.TempDir()
+	setupRemote(t, remoteDir, "TouchBistro/hype")
+	remotePath := filepath.Join(remoteDir, "TouchBistro/hype.git")
+	// Pushing over HTTP is disabled by default for unauthenticated users.
+	runGit(t, remotePath, "config", "http.receivepack", "true")
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatalf("failed to find git: %v", err)
+	}
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + remoteDir, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "x-access-token" || pass != "hype-token" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="hype"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_TOKEN", "hype-token")
+
+	host, err := git.Host{
+		Transport: git.TransportHTTPS,
+		CloneURL:  srv.URL + "/${REPO}.git",
+	}.WithDefaults()
+	if err != nil {
+		t.Fatalf("invalid host: %v", err)
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo, err := git.Prepare(ctx, "TouchBistro/hype", dir, "master", host)
+	if err != nil {
+		t.Fatalf("failed to clone repo: %v", err)
+	}
+	if err := repo.CreateBranch("cannon/test"); err != nil {
+		t.Fatalf("failed to create branch: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.Path(), "hype.txt"), []byte("hype\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	hash, err := repo.CommitChanges(ctx, "Add hype")
+	if err != nil {
+		t.Fatalf("failed to commit changes: %v", err)
+	}
+	if err := repo.Push(ctx); err != nil {
+		t.Fatalf("failed to push changes: %v", err)
+	}
+	if got := runGit(t, remotePath, "rev-parse", "cannon/test"); got != hash {
+		t.Errorf("got remote branch at %s, want %s", got, hash)
+	}
+
+	// Preparing again pulls over HTTPS.
+	if _, err := git.Prepare(ctx, "TouchBistro/hype", dir, "master", host); err != nil {
+		t.Fatalf("failed to update repo: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// gitea is a Provider for Gitea and Forgejo.
+// The API is similar to GitHub's but differs in the auth header, path prefix and error bodies.
+type gitea struct {
+	host Host
+}
+
+func (gt gitea) CloneURL(repo string) (string, error) {
+	return gt.host.cloneURL(repo)
+}
+
+func (gt gitea) CreatePRURL(pr PullRequest) string {
+	if pr.Base == "" {
+		// Gitea compares against the default branch if no base is given.
+		return gt.host.webURL("/%s/compare/%s", pr.Repo, pr.Head)
+	}
+	return gt.host.webURL("/%s/compare/%s...%s", pr.Repo, pr.Base, pr.Head)
+}
+
+func (gt gitea) GitAuth(ctx context.Context) (transport.AuthMethod, error) {
+	token := os.Getenv("GITEA_TOKEN")
+	if token == "" {
+		return nil, nil
+	}
+	// Gitea accepts an access token as the password for any username.
+	return &githttp.BasicAuth{Username: "cannon", Password: token}, nil
+}
+
+func (gt gitea) CreatePR(ctx context.Context, pr PullRequest) (string, error) {
+	// Labels are set by ID which we don't have.
+	if err := pr.unsupported("Gitea", "labels"); err != nil {
+		return "", err
+	}
+	title := pr.Title
+	if pr.Draft {
+		// Gitea marks pull requests as work in progress based on the title.
+		title = "WIP: " + title
+	}
+	rb, err := gt.findPR(ctx, pr)
+	if err != nil {
+		return "", err
+	}
+	if rb != nil {
+		endpoint := gt.host.apiURL("/repos/%s/pulls/%d", pr.Repo, rb.Number)
+		err := gt.request(http.MethodPatch, endpoint, map[string]any{
+			"title":     title,
+			"body":      pr.Body,
+			"assignees": pr.Assignees,
+		}, http.StatusCreated).do(ctx, nil)
+		if err != nil {
+			return rb.HTMLURL, fmt.Errorf("unable to update PR %s: %w", rb.HTMLURL, err)
+		}
+	} else {
+		rb = &giteaPR{}
+		err := gt.request(http.MethodPost, gt.host.apiURL("/repos/%s/pulls", pr.Repo), map[string]any{
+			"title":     title,
+			"head":      pr.Head,
+			"base":      pr.Base,
+			"body":      pr.Body,
+			"assignees": pr.Assignees,
+		}, http.StatusCreated).do(ctx, rb)
+		if err != nil {
+			return "", fmt.Errorf("unable to create PR for repo %s: %w", pr.Repo, err)
+		}
+	}
+	if len(pr.Reviewers) > 0 || len(pr.TeamReviewers) > 0 {
+		endpoint := gt.host.apiURL("/repos/%s/pulls/%d/requested_reviewers", pr.Repo, rb.Number)
+		err := gt.request(http.MethodPost, endpoint, map[string]any{
+			"reviewers":      nonNil(pr.Reviewers),
+			"team_reviewers": nonNil(pr.TeamReviewers),
+		}, http.StatusCreated).do(ctx, nil)
+		if err != nil {
+			return rb.HTMLURL, fmt.Errorf("unable to request reviewers for PR %s: %w", rb.HTMLURL, err)
+		}
+	}
+	return rb.HTMLURL, nil
+}
+
+// giteaPR is a pull request returned by the Gitea API.
+type giteaPR struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+}
+
+// findPR returns the open PR for the head and base branches of pr.
+// It returns nil if there is no such PR.
+func (gt gitea) findPR(ctx context.Context, pr PullRequest) (*giteaPR, error) {
+	var rb giteaPR
+	endpoint := gt.host.apiURL("/repos/%s/pulls/%s/%s", pr.Repo, pr.Base, pr.Head)
+	err := gt.request(http.MethodGet, endpoint, nil, http.StatusOK).do(ctx, &rb)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.statusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get existing PR for repo %s: %w", pr.Repo, err)
+	}
+	// The most recent PR is returned even if it was closed.
+	if rb.State != "open" {
+		return nil, nil
+	}
+	return &rb, nil
+}
+
+func (gt gitea) request(method, endpoint string, body any, wantStatus int) apiRequest {
+	header := make(http.Header)
+	header.Set("Authorization", "token "+os.Getenv("GITEA_TOKEN"))
+	header.Set("Accept", "application/json")
+	return apiRequest{
+		name:         "Gitea",
+		method:       method,
+		url:          endpoint,
+		header:       header,
+		body:         body,
+		wantStatus:   wantStatus,
+		errorMessage: giteaErrorMessage,
+	}
+}
+
+// giteaErrorMessage returns the message from a Gitea API error.
+// Errors are of the form {"message": "...", "url": "..."}.
+func giteaErrorMessage(body []byte) string {
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return ""
+	}
+	return apiErr.Message
+}
@@ -0,0 +1,93 @@
+package git_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TouchBistro/cannon/git"
+)
+
+func TestGiteaCreatePR(t *testing.T) {
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/v1/repos/TouchBistro/hype/pulls/master/cannon/test" {
+			// No existing PR.
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "pull request does not exist"}`))
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/repos/TouchBistro/hype/pulls" {
+			t.Errorf("got unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "token hype-token" {
+			t.Errorf("got Authorization header %q, want %q", got, "token hype-token")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number": 1, "html_url": "https://gitea.example.com/TouchBistro/hype/pulls/1"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("GITEA_TOKEN", "hype-token")
+
+	repo := prepareRepo(t, "TouchBistro/hype", git.Host{
+		Type:   git.HostTypeGitea,
+		APIURL: srv.URL + "/api/v1",
+		WebURL: "https://gitea.example.com",
+	})
+	prURL, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test", Body: "Hype changes"})
+	if err != nil {
+		t.Fatalf("failed to create pull request: %v", err)
+	}
+	if want := "https://gitea.example.com/TouchBistro/hype/pulls/1"; prURL != want {
+		t.Errorf("got pull request URL %s, want %s", prURL, want)
+	}
+	want := map[string]string{
+		"head":  "cannon/test",
+		"base":  "master",
+		"title": "cannon/test",
+		"body":  "Hype changes",
+	}
+	for k, v := range want {
+		if gotBody[k] != v {
+			t.Errorf("got %s %q in request body, want %q", k, gotBody[k], v)
+		}
+	}
+
+	wantURL := "https://gitea.example.com/TouchBistro/hype/compare/master...cannon/test"
+	if got := repo.CreatePRURL("cannon/test"); got != wantURL {
+		t.Errorf("got create pull request URL %s, want %s", got, wantURL)
+	}
+}
+
+func TestGiteaCreatePRError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"message": "pull request already exists for these targets", "url": "https://gitea.example.com/api/swagger"}`))
+	}))
+	defer srv.Close()
+
+	repo := prepareRepo(t, "TouchBistro/hype", git.Host{
+		Type:   git.HostTypeGitea,
+		APIURL: srv.URL + "/api/v1",
+		WebURL: "https://gitea.example.com",
+	})
+	_, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test", Body: "Hype changes"})
+	if err == nil {
+		t.Fatal("want error when pull request creation fails")
+	}
+	if !strings.Contains(err.Error(), "pull request already exists for these targets") {
+		t.Errorf("got error %q, want it to contain the message from the API", err)
+	}
+}
@@ -0,0 +1,198 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// gitHub is a Provider for GitHub and GitHub Enterprise Server.
+type gitHub struct {
+	host Host
+}
+
+func (gh gitHub) CloneURL(repo string) (string, error) {
+	return gh.host.cloneURL(repo)
+}
+
+func (gh gitHub) CreatePRURL(pr PullRequest) string {
+	return gh.host.webURL("/%s/pull/new/%s", pr.Repo, pr.Head)
+}
+
+// gitHubPR is a pull request returned by the GitHub API.
+type gitHubPR struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+func (gh gitHub) CreatePR(ctx context.Context, pr PullRequest) (string, error) {
+	rb, err := gh.findPR(ctx, pr)
+	if err != nil {
+		return "", err
+	}
+	if rb != nil {
+		// Draft status can't be changed through the REST API so leave it as is.
+		endpoint := gh.host.apiURL("/repos/%s/pulls/%d", pr.Repo, rb.Number)
+		err := gh.request(http.MethodPatch, endpoint, map[string]any{
+			"title": pr.Title,
+			"body":  pr.Body,
+		}, http.StatusOK).do(ctx, nil)
+		if err != nil {
+			return rb.HTMLURL, fmt.Errorf("unable to update PR %s: %w", rb.HTMLURL, err)
+		}
+	} else {
+		rb = &gitHubPR{}
+		err := gh.request(http.MethodPost, gh.host.apiURL("/repos/%s/pulls", pr.Repo), map[string]any{
+			"title": pr.Title,
+			"head":  pr.Head,
+			"base":  pr.Base,
+			"body":  pr.Body,
+			"draft": pr.Draft,
+		}, http.StatusCreated).do(ctx, rb)
+		if err != nil {
+			return "", fmt.Errorf("unable to create PR for repo %s: %w", pr.Repo, err)
+		}
+	}
+
+	// Labels, assignees and reviewers can't be set when creating a PR,
+	// they have to be added afterwards.
+	// PRs are issues as far as labels and assignees are concerned.
+	if len(pr.Labels) > 0 {
+		endpoint := gh.host.apiURL("/repos/%s/issues/%d/labels", pr.Repo, rb.Number)
+		err := gh.request(http.MethodPost, endpoint, map[string]any{
+			"labels": pr.Labels,
+		}, http.StatusOK).do(ctx, nil)
+		if err != nil {
+			return rb.HTMLURL, fmt.Errorf("unable to add labels to PR %s: %w", rb.HTMLURL, err)
+		}
+	}
+	if len(pr.Assignees) > 0 {
+		endpoint := gh.host.apiURL("/repos/%s/issues/%d/assignees", pr.Repo, rb.Number)
+		err := gh.request(http.MethodPost, endpoint, map[string]any{
+			"assignees": pr.Assignees,
+		}, http.StatusCreated).do(ctx, nil)
+		if err != nil {
+			return rb.HTMLURL, fmt.Errorf("unable to add assignees to PR %s: %w", rb.HTMLURL, err)
+		}
+	}
+	if len(pr.Reviewers) > 0 || len(pr.TeamReviewers) > 0 {
+		endpoint := gh.host.apiURL("/repos/%s/pulls/%d/requested_reviewers", pr.Repo, rb.Number)
+		err := gh.request(http.MethodPost, endpoint, map[string]any{
+			"reviewers":      nonNil(pr.Reviewers),
+			"team_reviewers": nonNil(pr.TeamReviewers),
+		}, http.StatusCreated).do(ctx, nil)
+		if err != nil {
+			return rb.HTMLURL, fmt.Errorf("unable to request reviewers for PR %s: %w", rb.HTMLURL, err)
+		}
+	}
+	return rb.HTMLURL, nil
+}
+
+// findPR returns the open PR for the head and base branches of pr.
+// It returns nil if there is no such PR.
+func (gh gitHub) findPR(ctx context.Context, pr PullRequest) (*gitHubPR, error) {
+	owner, _, _ := strings.Cut(pr.Repo, "/")
+	q := make(url.Values)
+	q.Set("state", "open")
+	// The head must be qualified by the owner, otherwise it is ignored.
+	q.Set("head", owner+":"+pr.Head)
+	q.Set("base", pr.Base)
+	var prs []gitHubPR
+	err := gh.request(http.MethodGet, gh.host.apiURL("/repos/%s/pulls?%s", pr.Repo, q.Encode()), nil, http.StatusOK).do(ctx, &prs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get existing PRs for repo %s: %w", pr.Repo, err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return &prs[0], nil
+}
+
+func (gh gitHub) GitAuth(ctx context.Context) (transport.AuthMethod, error) {
+	token, err := gh.token(ctx)
+	if err != nil || token == "" {
+		return nil, err
+	}
+	// The username can be anything but must not be empty.
+	return &githttp.BasicAuth{Username: "x-access-token", Password: token}, nil
+}
+
+// token returns the token to authenticate with, either the
+// GitHub App installation token or GITHUB_TOKEN.
+func (gh gitHub) token(ctx context.Context) (string, error) {
+	if gh.host.App.enabled() {
+		return gh.host.appTokenSource().accessToken(ctx)
+	}
+	return os.Getenv("GITHUB_TOKEN"), nil
+}
+
+func (gh gitHub) request(method, endpoint string, body any, wantStatus int) apiRequest {
+	header := make(http.Header)
+	// Use v3 API
+	header.Set("Accept", "application/vnd.github.v3+json")
+	return apiRequest{
+		name:       "GitHub",
+		method:     method,
+		url:        endpoint,
+		header:     header,
+		body:       body,
+		wantStatus: wantStatus,
+		authorize: func(ctx context.Context, req *http.Request) error {
+			token, err := gh.token(ctx)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "token "+token)
+			return nil
+		},
+		errorMessage: gitHubErrorMessage,
+	}
+}
+
+// gitHubErrorMessage returns the message from a GitHub API error.
+// Validation errors include the details of each error, e.g. that a PR already exists.
+// See https://docs.github.com/en/rest/overview/resources-in-the-rest-api#client-errors.
+func gitHubErrorMessage(body []byte) string {
+	var apiErr struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Resource string `json:"resource"`
+			Field    string `json:"field"`
+			Code     string `json:"code"`
+			Message  string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return ""
+	}
+	var details []string
+	for _, e := range apiErr.Errors {
+		switch {
+		case e.Message != "":
+			details = append(details, e.Message)
+		case e.Field != "":
+			details = append(details, fmt.Sprintf("%s %s is %s", e.Resource, e.Field, e.Code))
+		case e.Code != "":
+			details = append(details, e.Code)
+		}
+	}
+	if len(details) == 0 {
+		return apiErr.Message
+	}
+	return fmt.Sprintf("%s: %s", apiErr.Message, strings.Join(details, "; "))
+}
+
+// nonNil returns s or an empty slice if s is nil so it is encoded as an empty JSON array instead of null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
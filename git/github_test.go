@@ -0,0 +1,307 @@
+package git_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TouchBistro/cannon/git"
+)
+
+func TestGitHubCreatePR(t *testing.T) {
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/v3/repos/TouchBistro/hype/pulls" {
+			// No existing PRs.
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/repos/TouchBistro/hype/pulls" {
+			t.Errorf("got unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "token hype-token" {
+			t.Errorf("got Authorization header %q, want %q", got, "token hype-token")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"html_url": "https://github.example.com/TouchBistro/hype/pull/1"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_TOKEN", "hype-token")
+
+	repo := prepareRepo(t, "TouchBistro/hype", git.Host{
+		APIURL: srv.URL + "/api/v3",
+		WebURL: "https://github.example.com",
+	})
+	prURL, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test", Body: "Hype changes"})
+	if err != nil {
+		t.Fatalf("failed to create PR: %v", err)
+	}
+	if want := "https://github.example.com/TouchBistro/hype/pull/1"; prURL != want {
+		t.Errorf("got PR URL %s, want %s", prURL, want)
+	}
+	want := map[string]string{
+		"title": "cannon/test",
+		"head":  "cannon/test",
+		"base":  "master",
+		"body":  "Hype changes",
+	}
+	for k, v := range want {
+		if gotBody[k] != v {
+			t.Errorf("got %s %q in request body, want %q", k, gotBody[k], v)
+		}
+	}
+	if got, want := repo.CreatePRURL("cannon/test"), "https://github.example.com/TouchBistro/hype/pull/new/cannon/test"; got != want {
+		t.Errorf("got create PR URL %s, want %s", got, want)
+	}
+}
+
+func TestGitHubCreatePROptions(t *testing.T) {
+	gotBodies := make(map[string]map[string]any)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		gotBodies[r.URL.Path] = body
+		switch r.URL.Path {
+		case "/repos/TouchBistro/hype/pulls":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"number": 12, "html_url": "https://github.com/TouchBistro/hype/pull/12"}`))
+		case "/repos/TouchBistro/hype/issues/12/labels":
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`[]`))
+		case "/repos/TouchBistro/hype/issues/12/assignees", "/repos/TouchBistro/hype/pulls/12/requested_reviewers":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("got unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	repo := prepareRepo(t, "TouchBistro/hype", git.Host{APIURL: srv.URL})
+	prURL, err := repo.CreatePR(context.Background(), git.PullRequest{
+		Head:          "cannon/test",
+		Title:         "Update hype",
+		Labels:        []string{"cannon", "chore"},
+		Reviewers:     []string{"hype-dev"},
+		TeamReviewers: []string{"platform"},
+		Assignees:     []string{"hype-lead"},
+		Draft:         true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create PR: %v", err)
+	}
+	if want := "https://github.com/TouchBistro/hype/pull/12"; prURL != want {
+		t.Errorf("got PR URL %s, want %s", prURL, want)
+	}
+
+	tests := []struct {
+		path string
+		key  string
+		want string
+	}{
+		{"/repos/TouchBistro/hype/pulls", "title", `"Update hype"`},
+		{"/repos/TouchBistro/hype/pulls", "draft", `true`},
+		{"/repos/TouchBistro/hype/issues/12/labels", "labels", `["cannon","chore"]`},
+		{"/repos/TouchBistro/hype/issues/12/assignees", "assignees", `["hype-lead"]`},
+		{"/repos/TouchBistro/hype/pulls/12/requested_reviewers", "reviewers", `["hype-dev"]`},
+		{"/repos/TouchBistro/hype/pulls/12/requested_reviewers", "team_reviewers", `["platform"]`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(gotBodies[tt.path][tt.key])
+		if err != nil {
+			t.Fatalf("failed to encode %s: %v", tt.key, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("got %s %s in request to %s, want %s", tt.key, data, tt.path, tt.want)
+		}
+	}
+}
+
+func TestGitHubUpdateExistingPR(t *testing.T) {
+	var gotQuery string
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/TouchBistro/hype/pulls":
+			gotQuery = r.URL.RawQuery
+			_, _ = w.Write([]byte(`[{"number": 7, "html_url": "https://github.com/TouchBistro/hype/pull/7"}]`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/TouchBistro/hype/pulls/7":
+			if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+				t.Errorf("failed to decode request body: %v", err)
+			}
+			_, _ = w.Write([]byte(`{"number": 7}`))
+		default:
+			t.Errorf("got unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	}))
+	defer srv.Close()
+
+	repo := prepareRepo(t, "TouchBistro/hype", git.Host{APIURL: srv.URL})
+	prURL, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/stable", Body: "More hype"})
+	if err != nil {
+		t.Fatalf("failed to update PR: %v", err)
+	}
+	if want := "https://github.com/TouchBistro/hype/pull/7"; prURL != want {
+		t.Errorf("got PR URL %s, want %s", prURL, want)
+	}
+	if want := "base=master&head=TouchBistro%3Acannon%2Fstable&state=open"; gotQuery != want {
+		t.Errorf("got query %s, want %s", gotQuery, want)
+	}
+	if gotBody["body"] != "More hype" || gotBody["title"] != "cannon/stable" {
+		t.Errorf("got request body %v, want updated title and body", gotBody)
+	}
+}
+
+func TestGitHubCreatePRError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{
+			"message": "Validation Failed",
+			"errors": [{"resource": "PullRequest", "code": "custom", "message": "A pull request already exists for TouchBistro:cannon/test."}],
+			"documentation_url": "https://docs.github.com/rest/reference/pulls#create-a-pull-request"
+		}`))
+	}))
+	defer srv.Close()
+
+	repo := prepareRepo(t, "TouchBistro/hype", git.Host{APIURL: srv.URL})
+	_, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test"})
+	if err == nil {
+		t.Fatal("want error when PR creation fails")
+	}
+	want := "got 422 response from GitHub API: Validation Failed: A pull request already exists for TouchBistro:cannon/test."
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want it to contain %q", err, want)
+	}
+}
+
+func TestGitHubRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		listResponses []func(w http.ResponseWriter) // responses to the request for existing pull requests
+		responses     []func(w http.ResponseWriter) // responses to the request creating the pull request
+		wantErr       bool
+	}{
+		{
+			name: "rate limit",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+					w.WriteHeader(http.StatusForbidden)
+				},
+			},
+		},
+		{
+			name: "server error listing pull requests",
+			listResponses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusBadGateway)
+				},
+			},
+		},
+		{
+			// The pull request may have been created so creating it again could fail or create a duplicate.
+			name: "server error creating pull request",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusBadGateway)
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "forbidden",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusForbidden)
+					_, _ = w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "rate limit reset too far away",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+					w.WriteHeader(http.StatusForbidden)
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listAttempts, attempts := 0, 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					listAttempts++
+					if listAttempts <= len(tt.listResponses) {
+						tt.listResponses[listAttempts-1](w)
+						return
+					}
+					_, _ = w.Write([]byte(`[]`))
+					return
+				}
+				attempts++
+				if attempts <= len(tt.responses) {
+					tt.responses[attempts-1](w)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"number": 1, "html_url": "https://github.com/TouchBistro/hype/pull/1"}`))
+			}))
+			defer srv.Close()
+
+			repo := prepareRepo(t, "TouchBistro/hype", git.Host{APIURL: srv.URL})
+			_, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test"})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error, got nil")
+				}
+				if attempts != 1 {
+					t.Errorf("got %d attempts, want 1", attempts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want no error, got %v", err)
+			}
+			if want := len(tt.listResponses) + 1; listAttempts != want {
+				t.Errorf("got %d attempts to list pull requests, want %d", listAttempts, want)
+			}
+			if want := len(tt.responses) + 1; attempts != want {
+				t.Errorf("got %d attempts, want %d", attempts, want)
+			}
+		})
+	}
+}
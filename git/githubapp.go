@@ -0,0 +1,179 @@
+package git
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GitHubApp is a GitHub App installation to authenticate as.
+// This allows changes to be made by the app instead of an individual user.
+type GitHubApp struct {
+	// ID is the ID of the GitHub App.
+	ID int64 `yaml:"id"`
+	// InstallationID is the ID of the installation of the app on the org that owns the repos.
+	InstallationID int64 `yaml:"installationID"`
+	// PrivateKeyPath is the path to the PEM encoded private key of the app.
+	// If empty, the key is read from the GITHUB_APP_PRIVATE_KEY environment variable.
+	PrivateKeyPath string `yaml:"privateKeyPath"`
+}
+
+func (app GitHubApp) enabled() bool {
+	return app != GitHubApp{}
+}
+
+func (app GitHubApp) validate() error {
+	if app.ID == 0 {
+		return errors.New("GitHub App ID must be set")
+	}
+	if app.InstallationID == 0 {
+		return errors.New("GitHub App installation ID must be set")
+	}
+	if app.PrivateKeyPath == "" && os.Getenv("GITHUB_APP_PRIVATE_KEY") == "" {
+		return errors.New("GitHub App private key path must be set or GITHUB_APP_PRIVATE_KEY must be set")
+	}
+	return nil
+}
+
+// appTokenSources contains the token source for each app installation so that
+// tokens are shared by all repos for the duration of the run.
+var appTokenSources = struct {
+	mu sync.Mutex
+	m  map[string]*appTokenSource
+}{m: make(map[string]*appTokenSource)}
+
+// appTokenSource returns the token source for the app installation on the host.
+func (h Host) appTokenSource() *appTokenSource {
+	key := fmt.Sprintf("%s|%d|%d", h.APIURL, h.App.ID, h.App.InstallationID)
+	appTokenSources.mu.Lock()
+	defer appTokenSources.mu.Unlock()
+	ts, ok := appTokenSources.m[key]
+	if !ok {
+		ts = &appTokenSource{host: h}
+		appTokenSources.m[key] = ts
+	}
+	return ts
+}
+
+// appTokenSource creates installation access tokens for a GitHub App.
+// Tokens are cached and are only refreshed when they are about to expire.
+type appTokenSource struct {
+	host Host
+
+	mu      sync.Mutex
+	key     *rsa.PrivateKey
+	token   string
+	expires time.Time
+}
+
+// accessToken returns an installation access token for the app.
+func (ts *appTokenSource) accessToken(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	// Refresh a bit early so the token doesn't expire while it is being used.
+	if ts.token != "" && time.Until(ts.expires) > 5*time.Minute {
+		return ts.token, nil
+	}
+	if ts.key == nil {
+		key, err := ts.host.App.privateKey()
+		if err != nil {
+			return "", err
+		}
+		ts.key = key
+	}
+	jwt, err := appJWT(ts.host.App.ID, ts.key, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+jwt)
+	header.Set("Accept", "application/vnd.github.v3+json")
+	var rb struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = apiRequest{
+		name:         "GitHub",
+		method:       http.MethodPost,
+		url:          ts.host.apiURL("/app/installations/%d/access_tokens", ts.host.App.InstallationID),
+		header:       header,
+		wantStatus:   http.StatusCreated,
+		errorMessage: gitHubErrorMessage,
+	}.do(ctx, &rb)
+	if err != nil {
+		return "", fmt.Errorf("unable to create access token for GitHub App installation %d: %w", ts.host.App.InstallationID, err)
+	}
+	ts.token = rb.Token
+	ts.expires = rb.ExpiresAt
+	return ts.token, nil
+}
+
+// privateKey reads and parses the private key of the app.
+func (app GitHubApp) privateKey() (*rsa.PrivateKey, error) {
+	data := []byte(os.Getenv("GITHUB_APP_PRIVATE_KEY"))
+	if app.PrivateKeyPath != "" {
+		var err error
+		data, err = os.ReadFile(app.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+		}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("GitHub App private key is not PEM encoded")
+	}
+	// GitHub provides keys in PKCS #1 but allow PKCS #8 in case the key was converted.
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key must be an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// appJWT creates a JWT signed with RS256 to authenticate as the app with the given ID.
+// See https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app.
+func appJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT header: %w", err)
+	}
+	claims, err := json.Marshal(map[string]any{
+		// Backdate to allow for clock drift.
+		"iat": now.Add(-time.Minute).Unix(),
+		// GitHub allows a maximum of 10 minutes.
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT claims: %w", err)
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
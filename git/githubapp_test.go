@@ -0,0 +1,130 @@
+package git_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TouchBistro/cannon/git"
+)
+
+// verifyJWT checks that jwt is signed by key and returns its claims.
+func verifyJWT(t *testing.T, jwt string, key *rsa.PublicKey) map[string]any {
+	t.Helper()
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("got JWT with %d parts, want 3", len(parts))
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("failed to decode JWT signature: %v", err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+		t.Fatalf("invalid JWT signature: %v", err)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("failed to decode JWT claims: %v", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(data, &claims); err != nil {
+		t.Fatalf("failed to parse JWT claims: %v", err)
+	}
+	return claims
+}
+
+func TestGitHubAppAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	tokenRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/app/installations/42/access_tokens" {
+			tokenRequests++
+			jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			claims := verifyJWT(t, jwt, &key.PublicKey)
+			if claims["iss"] != "7" {
+				t.Errorf("got iss claim %v, want 7", claims["iss"])
+			}
+			w.WriteHeader(http.StatusCreated)
+			expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			fmt.Fprintf(w, `{"token": "installation-token", "expires_at": %q}`, expires)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "token installation-token" {
+			t.Errorf("got Authorization header %q for %s, want installation token", got, r.URL.Path)
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number": 1, "html_url": "https://github.com/TouchBistro/hype/pull/1"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_TOKEN", "personal-token")
+
+	host, err := git.Host{
+		// The clone URL is replaced with a local remote by prepareRepo.
+		CloneURL: "git@github.example.com:${REPO}.git",
+		APIURL:   srv.URL,
+		App:      git.GitHubApp{ID: 7, InstallationID: 42, PrivateKeyPath: keyPath},
+	}.WithDefaults()
+	if err != nil {
+		t.Fatalf("invalid host: %v", err)
+	}
+	repo := prepareRepo(t, "TouchBistro/hype", host)
+	for i := 0; i < 2; i++ {
+		if _, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test"}); err != nil {
+			t.Fatalf("failed to create PR: %v", err)
+		}
+	}
+	// The token must be reused until it expires.
+	if tokenRequests != 1 {
+		t.Errorf("got %d token requests, want 1", tokenRequests)
+	}
+}
+
+func TestGitHubAppInvalid(t *testing.T) {
+	t.Setenv("GITHUB_APP_PRIVATE_KEY", "")
+	tests := []struct {
+		name string
+		host git.Host
+	}{
+		{"missing installation", git.Host{App: git.GitHubApp{ID: 7, PrivateKeyPath: "app.pem"}}},
+		{"missing key", git.Host{App: git.GitHubApp{ID: 7, InstallationID: 42}}},
+		{"not github", git.Host{
+			Type: git.HostTypeGitLab,
+			App:  git.GitHubApp{ID: 7, InstallationID: 42, PrivateKeyPath: "app.pem"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.host.WithDefaults(); err == nil {
+				t.Error("want error for invalid GitHub App config")
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// gitLab is a Provider for GitLab.
+// Merge requests are created for the pull requests.
+type gitLab struct {
+	host Host
+}
+
+func (gl gitLab) CloneURL(repo string) (string, error) {
+	return gl.host.cloneURL(repo)
+}
+
+func (gl gitLab) CreatePRURL(pr PullRequest) string {
+	q := make(url.Values)
+	q.Set("merge_request[source_branch]", pr.Head)
+	if pr.Base != "" {
+		q.Set("merge_request[target_branch]", pr.Base)
+	}
+	return gl.host.webURL("/%s/-/merge_requests/new?%s", pr.Repo, q.Encode())
+}
+
+func (gl gitLab) GitAuth(ctx context.Context) (transport.AuthMethod, error) {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		return nil, nil
+	}
+	// GitLab requires this username for personal access tokens.
+	return &githttp.BasicAuth{Username: "oauth2", Password: token}, nil
+}
+
+func (gl gitLab) CreatePR(ctx context.Context, pr PullRequest) (string, error) {
+	// Reviewers and assignees are set by user ID which we don't have.
+	if err := pr.unsupported("GitLab", "reviewers", "teamReviewers", "assignees"); err != nil {
+		return "", err
+	}
+	title := pr.Title
+	if pr.Draft {
+		// GitLab marks merge requests as drafts based on the title.
+		title = "Draft: " + title
+	}
+	body := map[string]string{
+		"title":       title,
+		"description": pr.Body,
+	}
+	// Only send labels if there are some, otherwise GitLab removes all labels from existing merge requests.
+	if len(pr.Labels) > 0 {
+		body["labels"] = strings.Join(pr.Labels, ",")
+	}
+
+	q := make(url.Values)
+	q.Set("state", "opened")
+	q.Set("source_branch", pr.Head)
+	q.Set("target_branch", pr.Base)
+	var mrs []gitLabMR
+	err := gl.request(http.MethodGet, gl.projectURL(pr.Repo, "/merge_requests?"+q.Encode()), nil, http.StatusOK).do(ctx, &mrs)
+	if err != nil {
+		return "", fmt.Errorf("unable to get existing merge requests for repo %s: %w", pr.Repo, err)
+	}
+	if len(mrs) > 0 {
+		mr := mrs[0]
+		endpoint := gl.projectURL(pr.Repo, fmt.Sprintf("/merge_requests/%d", mr.IID))
+		if err := gl.request(http.MethodPut, endpoint, body, http.StatusOK).do(ctx, nil); err != nil {
+			return mr.WebURL, fmt.Errorf("unable to update merge request %s: %w", mr.WebURL, err)
+		}
+		return mr.WebURL, nil
+	}
+
+	body["source_branch"] = pr.Head
+	body["target_branch"] = pr.Base
+	var mr gitLabMR
+	err = gl.request(http.MethodPost, gl.projectURL(pr.Repo, "/merge_requests"), body, http.StatusCreated).do(ctx, &mr)
+	if err != nil {
+		return "", fmt.Errorf("unable to create merge request for repo %s: %w", pr.Repo, err)
+	}
+	return mr.WebURL, nil
+}
+
+// gitLabMR is a merge request returned by the GitLab API.
+type gitLabMR struct {
+	IID    int    `json:"iid"`
+	WebURL string `json:"web_url"`
+}
+
+// projectURL returns the URL of an API endpoint for the project.
+// GitLab identifies projects by their full path which must be URL encoded.
+func (gl gitLab) projectURL(repo, path string) string {
+	return gl.host.apiURL("/projects/%s%s", url.PathEscape(repo), path)
+}
+
+func (gl gitLab) request(method, endpoint string, body any, wantStatus int) apiRequest {
+	header := make(http.Header)
+	header.Set("PRIVATE-TOKEN", os.Getenv("GITLAB_TOKEN"))
+	return apiRequest{
+		name:       "GitLab",
+		method:     method,
+		url:        endpoint,
+		header:     header,
+		body:       body,
+		wantStatus: wantStatus,
+	}
+}
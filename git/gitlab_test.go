@@ -0,0 +1,107 @@
+package git_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TouchBistro/cannon/git"
+)
+
+func TestGitLabCreatePR(t *testing.T) {
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/touchbistro/platform/hype/merge_requests" {
+			// No existing merge requests.
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		// The project path must be encoded as a single path segment.
+		if r.Method != http.MethodPost || r.RequestURI != "/api/v4/projects/touchbistro%2Fplatform%2Fhype/merge_requests" {
+			t.Errorf("got unexpected request %s %s", r.Method, r.RequestURI)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("PRIVATE-TOKEN"); got != "hype-token" {
+			t.Errorf("got PRIVATE-TOKEN header %q, want %q", got, "hype-token")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"iid": 1, "web_url": "https://gitlab.example.com/touchbistro/platform/hype/-/merge_requests/1"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("GITLAB_TOKEN", "hype-token")
+
+	repo := prepareRepo(t, "touchbistro/platform/hype", git.Host{
+		Type:   git.HostTypeGitLab,
+		APIURL: srv.URL + "/api/v4",
+		WebURL: "https://gitlab.example.com",
+	})
+	prURL, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test", Body: "Hype changes"})
+	if err != nil {
+		t.Fatalf("failed to create merge request: %v", err)
+	}
+	if want := "https://gitlab.example.com/touchbistro/platform/hype/-/merge_requests/1"; prURL != want {
+		t.Errorf("got merge request URL %s, want %s", prURL, want)
+	}
+	want := map[string]string{
+		"source_branch": "cannon/test",
+		"target_branch": "master",
+		"title":         "cannon/test",
+		"description":   "Hype changes",
+	}
+	for k, v := range want {
+		if gotBody[k] != v {
+			t.Errorf("got %s %q in request body, want %q", k, gotBody[k], v)
+		}
+	}
+
+	wantURL := "https://gitlab.example.com/touchbistro/platform/hype/-/merge_requests/new?merge_request%5Bsource_branch%5D=cannon%2Ftest&merge_request%5Btarget_branch%5D=master"
+	if got := repo.CreatePRURL("cannon/test"); got != wantURL {
+		t.Errorf("got create merge request URL %s, want %s", got, wantURL)
+	}
+}
+
+func TestGitLabUpdateExistingMR(t *testing.T) {
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/touchbistro/hype/merge_requests":
+			_, _ = w.Write([]byte(`[{"iid": 3, "web_url": "https://gitlab.example.com/touchbistro/hype/-/merge_requests/3"}]`))
+		case r.Method == http.MethodPut && r.RequestURI == "/api/v4/projects/touchbistro%2Fhype/merge_requests/3":
+			if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+				t.Errorf("failed to decode request body: %v", err)
+			}
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("got unexpected request %s %s", r.Method, r.RequestURI)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITLAB_TOKEN", "hype-token")
+
+	repo := prepareRepo(t, "touchbistro/hype", git.Host{
+		Type:   git.HostTypeGitLab,
+		APIURL: srv.URL + "/api/v4",
+		WebURL: "https://gitlab.example.com",
+	})
+	prURL, err := repo.CreatePR(context.Background(), git.PullRequest{Head: "cannon/test", Title: "Hype", Body: "Hype changes"})
+	if err != nil {
+		t.Fatalf("failed to update merge request: %v", err)
+	}
+	if want := "https://gitlab.example.com/touchbistro/hype/-/merge_requests/3"; prURL != want {
+		t.Errorf("got merge request URL %s, want %s", prURL, want)
+	}
+	if gotBody["title"] != "Hype" || gotBody["description"] != "Hype changes" {
+		t.Errorf("got request body %v, want title and description to be updated", gotBody)
+	}
+	// Sending empty labels would remove any labels already on the merge request.
+	if labels, ok := gotBody["labels"]; ok {
+		t.Errorf("got labels %q in request body, want none", labels)
+	}
+}
@@ -0,0 +1,454 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TouchBistro/goutils/progress"
+	"github.com/TouchBistro/goutils/text"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Provider is a code hosting service that repos can be cloned from
+// and where pull requests can be created.
+type Provider interface {
+	// CloneURL returns the URL to clone the repo with the given name.
+	CloneURL(repo string) (string, error)
+	// CreatePRURL returns the URL of the web page to create the pull request.
+	CreatePRURL(pr PullRequest) string
+	// CreatePR creates the pull request and returns its URL.
+	// If an open pull request already exists for the same branches,
+	// it is updated to match pr instead.
+	CreatePR(ctx context.Context, pr PullRequest) (string, error)
+	// GitAuth returns the auth to use for git operations over HTTPS.
+	// If nil, the default auth is used.
+	GitAuth(ctx context.Context) (transport.AuthMethod, error)
+}
+
+// PullRequest describes a pull request to be created.
+type PullRequest struct {
+	// Repo is the full name of the repo.
+	Repo string
+	// Head is the branch containing the changes.
+	Head string
+	// Base is the branch the changes will be merged into.
+	Base string
+	// Title is the title of the pull request.
+	Title string
+	// Body is the description of the pull request.
+	Body string
+	// Labels are the names of labels to add to the pull request.
+	Labels []string
+	// Reviewers are the usernames of users to request a review from.
+	Reviewers []string
+	// TeamReviewers are the names of teams to request a review from.
+	TeamReviewers []string
+	// Assignees are the usernames of users to assign the pull request to.
+	Assignees []string
+	// Draft marks the pull request as a draft.
+	Draft bool
+}
+
+// unsupported returns an error if any of the named options are set on pr.
+// It is used by providers to reject options their API has no equivalent for,
+// rather than silently ignoring them.
+func (pr PullRequest) unsupported(provider string, options ...string) error {
+	for _, o := range options {
+		var set bool
+		switch o {
+		case "labels":
+			set = len(pr.Labels) > 0
+		case "reviewers":
+			set = len(pr.Reviewers) > 0
+		case "teamReviewers":
+			set = len(pr.TeamReviewers) > 0
+		case "assignees":
+			set = len(pr.Assignees) > 0
+		case "draft":
+			set = pr.Draft
+		default:
+			panic("impossible: unknown pull request option " + o)
+		}
+		if set {
+			return fmt.Errorf("pull request option %s is not supported by %s", o, provider)
+		}
+	}
+	return nil
+}
+
+// Supported types of hosts.
+const (
+	HostTypeGitHub    = "github"
+	HostTypeGitLab    = "gitlab"
+	HostTypeBitbucket = "bitbucket"
+	HostTypeGitea     = "gitea"
+)
+
+// Supported transports for git operations.
+const (
+	TransportSSH   = "ssh"
+	TransportHTTPS = "https"
+)
+
+// Host describes the server where repos are hosted.
+type Host struct {
+	// Type is the type of service, which determines the API that is used.
+	// Defaults to GitHub if empty.
+	Type string `yaml:"type"`
+	// CloneURL is a template for the URL used to clone a repo.
+	// The variables ${REPO}, ${REPO_OWNER} and ${REPO_NAME} will be expanded
+	// to the full name, owner and name of the repo respectively.
+	CloneURL string `yaml:"cloneURL"`
+	// APIURL is the base URL of the host's REST API.
+	APIURL string `yaml:"apiURL"`
+	// WebURL is the base URL of the host's web interface.
+	WebURL string `yaml:"webURL"`
+	// Transport is the transport used for git operations, either ssh or https. Defaults to ssh.
+	// It determines the default clone URL. With https, the token used for the
+	// host's API is also used to authenticate clones, pulls and pushes, so no SSH agent is needed.
+	Transport string `yaml:"transport"`
+	// App is a GitHub App to authenticate as instead of using GITHUB_TOKEN.
+	// Only supported by GitHub hosts.
+	App GitHubApp `yaml:"app"`
+}
+
+// defaultHosts contains the default values for the public instance of each type of host.
+var defaultHosts = map[string]Host{
+	HostTypeGitHub: {
+		Type:     HostTypeGitHub,
+		CloneURL: "git@github.com:${REPO}.git",
+		APIURL:   "https://api.github.com",
+		WebURL:   "https://github.com",
+	},
+	HostTypeGitLab: {
+		Type:     HostTypeGitLab,
+		CloneURL: "git@gitlab.com:${REPO}.git",
+		APIURL:   "https://gitlab.com/api/v4",
+		WebURL:   "https://gitlab.com",
+	},
+	// Bitbucket Server is always self-hosted so there are no defaults.
+	HostTypeBitbucket: {Type: HostTypeBitbucket},
+	// Gitea and Forgejo are self-hosted so there are no defaults.
+	HostTypeGitea: {Type: HostTypeGitea},
+}
+
+// defaultHTTPSCloneURLs contains the default clone URLs for the https transport.
+var defaultHTTPSCloneURLs = map[string]string{
+	HostTypeGitHub: "https://github.com/${REPO}.git",
+	HostTypeGitLab: "https://gitlab.com/${REPO}.git",
+}
+
+// Merge returns a copy of h where each empty field is set to the value from other.
+// If h and other are different types of hosts, h is returned as is since
+// the fields of other do not apply to it.
+func (h Host) Merge(other Host) Host {
+	if h.Type != "" && h.typ() != other.typ() {
+		return h
+	}
+	if h.Type == "" {
+		h.Type = other.Type
+	}
+	if h.CloneURL == "" {
+		h.CloneURL = other.CloneURL
+	}
+	if h.APIURL == "" {
+		h.APIURL = other.APIURL
+	}
+	if h.WebURL == "" {
+		h.WebURL = other.WebURL
+	}
+	if h.Transport == "" {
+		h.Transport = other.Transport
+	}
+	if !h.App.enabled() {
+		h.App = other.App
+	}
+	return h
+}
+
+// WithDefaults returns a copy of h where each empty field is set to the
+// default value for the type of host. It returns an error if the type is not
+// supported, if a field has no default and is not set, or if the clone URL
+// is not set for a self-hosted instance.
+func (h Host) WithDefaults() (Host, error) {
+	defaults, ok := defaultHosts[h.typ()]
+	if !ok {
+		return h, fmt.Errorf("unsupported host type %s", h.Type)
+	}
+	switch h.Transport {
+	case "", TransportSSH:
+	case TransportHTTPS:
+		defaults.CloneURL = defaultHTTPSCloneURLs[defaults.Type]
+	default:
+		return h, fmt.Errorf("unsupported transport %s, must be ssh or https", h.Transport)
+	}
+	// A custom API or web URL means the host is a self-hosted instance,
+	// so the default clone URL of the public instance would clone the wrong repos.
+	custom := (h.APIURL != "" && h.APIURL != defaults.APIURL) || (h.WebURL != "" && h.WebURL != defaults.WebURL)
+	if custom && h.CloneURL == "" && defaults.CloneURL != "" {
+		return h, fmt.Errorf("missing cloneURL for %s host, it must be set if apiURL or webURL is not the default", defaults.Type)
+	}
+	h = h.Merge(defaults)
+	var missing []string
+	if h.CloneURL == "" {
+		missing = append(missing, "cloneURL")
+	}
+	if h.APIURL == "" {
+		missing = append(missing, "apiURL")
+	}
+	if h.WebURL == "" {
+		missing = append(missing, "webURL")
+	}
+	if len(missing) > 0 {
+		return h, fmt.Errorf("missing %s for %s host", strings.Join(missing, ", "), h.Type)
+	}
+	if h.App.enabled() {
+		if h.Type != HostTypeGitHub {
+			return h, fmt.Errorf("GitHub App is not supported for %s host", h.Type)
+		}
+		if err := h.App.validate(); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+func (h Host) typ() string {
+	if h.Type == "" {
+		return HostTypeGitHub
+	}
+	return h.Type
+}
+
+// provider returns the Provider for the host.
+func (h Host) provider() (Provider, error) {
+	switch h.typ() {
+	case HostTypeGitHub:
+		return gitHub{host: h}, nil
+	case HostTypeGitLab:
+		return gitLab{host: h}, nil
+	case HostTypeBitbucket:
+		return bitbucket{host: h}, nil
+	case HostTypeGitea:
+		return gitea{host: h}, nil
+	default:
+		return nil, fmt.Errorf("unsupported host type %s", h.Type)
+	}
+}
+
+// cloneURL returns the URL to clone the repo with the given name.
+func (h Host) cloneURL(name string) (string, error) {
+	vars := map[string]string{"REPO": name}
+	if i := strings.LastIndexByte(name, '/'); i != -1 {
+		vars["REPO_OWNER"] = name[:i]
+		vars["REPO_NAME"] = name[i+1:]
+	}
+	vm := text.NewVariableMapper(vars)
+	url := text.ExpandVariables([]byte(h.CloneURL), vm.Map)
+	if len(vm.Missing()) > 0 {
+		return "", fmt.Errorf("failed to expand variables in clone URL %s, unknown variables %q", h.CloneURL, strings.Join(vm.Missing(), ", "))
+	}
+	return string(url), nil
+}
+
+// apiURL returns the URL for an API endpoint on the host.
+func (h Host) apiURL(format string, args ...any) string {
+	return strings.TrimSuffix(h.APIURL, "/") + fmt.Sprintf(format, args...)
+}
+
+// webURL returns the URL for a page on the host's web interface.
+func (h Host) webURL(format string, args ...any) string {
+	return strings.TrimSuffix(h.WebURL, "/") + fmt.Sprintf(format, args...)
+}
+
+// apiRequest describes a request to the REST API of a host.
+type apiRequest struct {
+	name       string      // name of the API; for errors
+	method     string      // HTTP method
+	url        string      // full URL of the endpoint
+	header     http.Header // additional headers, e.g. for auth
+	body       any         // encoded as JSON if not nil
+	wantStatus int         // the status code of a successful response
+	// authorize sets the credentials on the request. It is called for each attempt
+	// so that the credentials can be refreshed if needed. Optional.
+	authorize func(ctx context.Context, req *http.Request) error
+	// errorMessage extracts the error message from the body of an unsuccessful response.
+	// If nil or it returns an empty string, only the status code is reported.
+	errorMessage func(body []byte) string
+}
+
+// apiError is returned when an API responds with an unexpected status code.
+type apiError struct {
+	api        string // name of the API
+	statusCode int
+	message    string // the error message from the response body, if any
+}
+
+func (e *apiError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("got %d response from %s API", e.statusCode, e.api)
+	}
+	return fmt.Sprintf("got %d response from %s API: %s", e.statusCode, e.api, e.message)
+}
+
+// Limits for retrying requests that failed due to rate limits or server errors.
+const (
+	maxRetries     = 3
+	retryBaseDelay = time.Second
+	// Don't wait longer than this for a rate limit to reset, it's better to fail
+	// and let the user resume the run later.
+	maxRetryDelay = 5 * time.Minute
+)
+
+// do sends the request and decodes the JSON response body into out if it is not nil.
+// Requests that fail due to a rate limit or a server error are retried.
+func (ar apiRequest) do(ctx context.Context, out any) error {
+	var body []byte
+	if ar.body != nil {
+		var err error
+		body, err = json.Marshal(ar.body)
+		if err != nil {
+			return fmt.Errorf("failed to create JSON body for request: %w", err)
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := ar.send(ctx, body)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode == ar.wantStatus {
+			defer res.Body.Close()
+			if out == nil {
+				return nil
+			}
+			if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+				return fmt.Errorf("failed to decode JSON from response body: %w", err)
+			}
+			return nil
+		}
+
+		apiErr := &apiError{api: ar.name, statusCode: res.StatusCode}
+		data, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if ar.errorMessage != nil {
+			apiErr.message = ar.errorMessage(data)
+		}
+		delay, ok := retryDelay(res, attempt)
+		if !ok || attempt == maxRetries {
+			return apiErr
+		}
+		progress.TrackerFromContext(ctx).Debugf("%s, retrying in %s", apiErr, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// send sends a single attempt of the request.
+func (ar apiRequest) send(ctx context.Context, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, ar.method, ar.url, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request to %s API: %w", ar.method, ar.name, err)
+	}
+	for k, v := range ar.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ar.authorize != nil {
+		if err := ar.authorize(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s API: %w", ar.name, err)
+	}
+	return res, nil
+}
+
+// retryDelay returns how long to wait before retrying a request that got res.
+// It returns false if the request should not be retried.
+//
+// Rate limited requests wait for the time given by the Retry-After or
+// X-RateLimit-Reset headers. Server errors are retried with exponential backoff
+// but only for idempotent requests, since the server may have already processed
+// the request, for example creating a pull request before timing out.
+func retryDelay(res *http.Response, attempt int) (time.Duration, bool) {
+	backoff := retryBaseDelay << attempt
+	var delay time.Duration
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests || isRateLimited(res):
+		// GitHub uses both 403 and 429 for rate limits.
+		delay = backoff
+		if reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			delay = time.Until(time.Unix(reset, 0))
+		}
+		if d, ok := retryAfter(res); ok {
+			delay = d
+		}
+	case res.StatusCode >= 500 && isIdempotent(res.Request.Method):
+		delay = backoff
+		if d, ok := retryAfter(res); ok {
+			delay = d
+		}
+	default:
+		return 0, false
+	}
+	if delay > maxRetryDelay {
+		return 0, false
+	}
+	if delay < 0 {
+		// The rate limit has already reset.
+		delay = 0
+	}
+	return delay, true
+}
+
+// isIdempotent reports whether a request with method can safely be sent more than once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRateLimited reports whether res is a 403 response due to exceeding a rate limit.
+// A 403 is also returned for permission errors which must not be retried.
+func isRateLimited(res *http.Response) bool {
+	if res.StatusCode != http.StatusForbidden {
+		return false
+	}
+	return res.Header.Get("X-RateLimit-Remaining") == "0" || res.Header.Get("Retry-After") != ""
+}
+
+// retryAfter returns the duration from the Retry-After header of res if it is set.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
@@ -0,0 +1,112 @@
+package git_test
+
+import (
+	"testing"
+
+	"github.com/TouchBistro/cannon/git"
+)
+
+func TestHostWithDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		host git.Host
+		want git.Host
+	}{
+		{
+			name: "github",
+			host: git.Host{},
+			want: git.Host{
+				Type:     git.HostTypeGitHub,
+				CloneURL: "git@github.com:${REPO}.git",
+				APIURL:   "https://api.github.com",
+				WebURL:   "https://github.com",
+			},
+		},
+		{
+			name: "gitlab with custom API",
+			host: git.Host{Type: git.HostTypeGitLab, CloneURL: "git@gitlab.example.com:${REPO}.git", APIURL: "https://gitlab.example.com/api/v4"},
+			want: git.Host{
+				Type:     git.HostTypeGitLab,
+				CloneURL: "git@gitlab.example.com:${REPO}.git",
+				APIURL:   "https://gitlab.example.com/api/v4",
+				WebURL:   "https://gitlab.com",
+			},
+		},
+		{
+			name: "bitbucket",
+			host: git.Host{
+				Type:     git.HostTypeBitbucket,
+				CloneURL: "ssh://git@bitbucket.example.com:7999/${REPO}.git",
+				APIURL:   "https://bitbucket.example.com/rest/api/1.0",
+				WebURL:   "https://bitbucket.example.com",
+			},
+			want: git.Host{
+				Type:     git.HostTypeBitbucket,
+				CloneURL: "ssh://git@bitbucket.example.com:7999/${REPO}.git",
+				APIURL:   "https://bitbucket.example.com/rest/api/1.0",
+				WebURL:   "https://bitbucket.example.com",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.host.WithDefaults()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got host\n\t%+v\nwant\n\t%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostWithDefaultsMissingFields(t *testing.T) {
+	tests := []struct {
+		name string
+		host git.Host
+	}{
+		{"bitbucket", git.Host{Type: git.HostTypeBitbucket, APIURL: "https://bitbucket.example.com/rest/api/1.0"}},
+		{"github enterprise", git.Host{APIURL: "https://github.example.com/api/v3", WebURL: "https://github.example.com"}},
+		{"github enterprise https", git.Host{WebURL: "https://github.example.com", Transport: git.TransportHTTPS}},
+		{"gitlab self-managed", git.Host{Type: git.HostTypeGitLab, APIURL: "https://gitlab.example.com/api/v4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.host.WithDefaults(); err == nil {
+				t.Error("want error for host with missing fields")
+			}
+		})
+	}
+}
+
+func TestHostWithDefaultsTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		host      git.Host
+		wantClone string
+		wantErr   bool
+	}{
+		{"github https", git.Host{Transport: git.TransportHTTPS}, "https://github.com/${REPO}.git", false},
+		{"gitlab https", git.Host{Type: git.HostTypeGitLab, Transport: git.TransportHTTPS}, "https://gitlab.com/${REPO}.git", false},
+		{"custom clone URL", git.Host{Transport: git.TransportHTTPS, CloneURL: "https://github.example.com/${REPO}"}, "https://github.example.com/${REPO}", false},
+		{"unsupported", git.Host{Transport: "ftp"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.host.WithDefaults()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got.CloneURL != tt.wantClone {
+				t.Errorf("got clone URL %s, want %s", got.CloneURL, tt.wantClone)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RepoQuery describes a set of repos in a GitHub org.
+// A repo must match all the filters that are set.
+type RepoQuery struct {
+	// Org is the GitHub org or user that owns the repos.
+	Org string `yaml:"org"`
+	// Topics are topics the repo must have.
+	Topics []string `yaml:"topics"`
+	// Language is the primary language of the repo. It is case insensitive.
+	Language string `yaml:"language"`
+	// Name is a regular expression the name of the repo must match, not including the org.
+	Name string `yaml:"name"`
+	// IncludeArchived includes archived repos, which are excluded by default.
+	IncludeArchived bool `yaml:"includeArchived"`
+	// IncludeForks includes forked repos, which are excluded by default.
+	IncludeForks bool `yaml:"includeForks"`
+}
+
+// RemoteRepo is a repo found by a RepoQuery.
+type RemoteRepo struct {
+	// Name is the full name of the repo, i.e. owner/name.
+	Name string
+	// DefaultBranch is the default branch of the repo.
+	DefaultBranch string
+}
+
+// maxSearchResults is the maximum number of results the GitHub search API returns for a query.
+const maxSearchResults = 1000
+
+// FindRepos returns all repos on host that match q sorted by name.
+// Only GitHub hosts are supported.
+func FindRepos(ctx context.Context, host Host, q RepoQuery) ([]RemoteRepo, error) {
+	if host.typ() != HostTypeGitHub {
+		return nil, fmt.Errorf("repo queries are not supported for %s host", host.Type)
+	}
+	if q.Org == "" {
+		return nil, fmt.Errorf("repo query must have an org")
+	}
+	var nameRegex *regexp.Regexp
+	if q.Name != "" {
+		var err error
+		nameRegex, err = regexp.Compile(q.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name regex in repo query: %w", err)
+		}
+	}
+
+	gh := gitHub{host: host}
+	var repos []RemoteRepo
+	// The org endpoint only works for orgs, so use the search API which supports both
+	// orgs and users and can do most of the filtering on the server.
+	// Name regexes can't be expressed in a search so that filter is applied afterwards.
+	terms := []string{"user:" + q.Org}
+	for _, t := range q.Topics {
+		terms = append(terms, "topic:"+t)
+	}
+	if q.Language != "" {
+		terms = append(terms, "language:"+q.Language)
+	}
+	if !q.IncludeArchived {
+		terms = append(terms, "archived:false")
+	}
+	if q.IncludeForks {
+		terms = append(terms, "fork:true")
+	}
+	const perPage = 100
+	for page := 1; ; page++ {
+		params := make(url.Values)
+		params.Set("q", strings.Join(terms, " "))
+		params.Set("per_page", fmt.Sprint(perPage))
+		params.Set("page", fmt.Sprint(page))
+		var rb struct {
+			TotalCount        int  `json:"total_count"`
+			IncompleteResults bool `json:"incomplete_results"`
+			Items             []struct {
+				FullName      string `json:"full_name"`
+				Name          string `json:"name"`
+				DefaultBranch string `json:"default_branch"`
+			} `json:"items"`
+		}
+		err := gh.request(http.MethodGet, host.apiURL("/search/repositories?%s", params.Encode()), nil, http.StatusOK).do(ctx, &rb)
+		if err != nil {
+			return nil, fmt.Errorf("unable to search for repos in %s: %w", q.Org, err)
+		}
+		// Don't silently operate on a subset of the repos.
+		if rb.IncompleteResults {
+			return nil, fmt.Errorf("search for repos in %s timed out before all results were found", q.Org)
+		}
+		if rb.TotalCount > maxSearchResults {
+			return nil, fmt.Errorf("repo query matches %d repos in %s but at most %d are supported, add more filters", rb.TotalCount, q.Org, maxSearchResults)
+		}
+		for _, item := range rb.Items {
+			if nameRegex != nil && !nameRegex.MatchString(item.Name) {
+				continue
+			}
+			repos = append(repos, RemoteRepo{Name: item.FullName, DefaultBranch: item.DefaultBranch})
+		}
+		if len(rb.Items) < perPage || page*perPage >= rb.TotalCount {
+			break
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name < repos[j].Name
+	})
+	return repos, nil
+}
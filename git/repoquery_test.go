@@ -0,0 +1,82 @@
+package git_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TouchBistro/cannon/git"
+)
+
+func TestFindRepos(t *testing.T) {
+	type item struct {
+		FullName      string `json:"full_name"`
+		Name          string `json:"name"`
+		DefaultBranch string `json:"default_branch"`
+	}
+	// 150 repos so that two pages are needed.
+	var items []item
+	for i := 149; i >= 0; i-- {
+		name := fmt.Sprintf("service-%03d", i)
+		if i%2 == 1 {
+			name = fmt.Sprintf("lib-%03d", i)
+		}
+		items = append(items, item{FullName: "TouchBistro/" + name, Name: name, DefaultBranch: "main"})
+	}
+	var gotQueries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search/repositories" {
+			t.Errorf("got unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		q := r.URL.Query()
+		gotQueries = append(gotQueries, q.Get("q"))
+		page := items[:100]
+		if q.Get("page") == "2" {
+			page = items[100:]
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"total_count":        len(items),
+			"incomplete_results": false,
+			"items":              page,
+		})
+	}))
+	defer srv.Close()
+
+	repos, err := git.FindRepos(context.Background(), git.Host{APIURL: srv.URL}, git.RepoQuery{
+		Org:      "TouchBistro",
+		Topics:   []string{"node", "backend"},
+		Language: "TypeScript",
+		Name:     "^service-",
+	})
+	if err != nil {
+		t.Fatalf("failed to find repos: %v", err)
+	}
+	if len(gotQueries) != 2 {
+		t.Fatalf("got %d requests, want 2", len(gotQueries))
+	}
+	wantQuery := "user:TouchBistro topic:node topic:backend language:TypeScript archived:false"
+	if gotQueries[0] != wantQuery {
+		t.Errorf("got query %q, want %q", gotQueries[0], wantQuery)
+	}
+	if len(repos) != 75 {
+		t.Fatalf("got %d repos, want 75", len(repos))
+	}
+	if repos[0].Name != "TouchBistro/service-000" || repos[74].Name != "TouchBistro/service-148" {
+		t.Errorf("got repos from %s to %s, want them sorted by name", repos[0].Name, repos[74].Name)
+	}
+	if repos[0].DefaultBranch != "main" {
+		t.Errorf("got default branch %s, want main", repos[0].DefaultBranch)
+	}
+}
+
+func TestFindReposUnsupportedHost(t *testing.T) {
+	_, err := git.FindRepos(context.Background(), git.Host{Type: git.HostTypeGitLab}, git.RepoQuery{Org: "touchbistro"})
+	if err == nil {
+		t.Error("want error for repo query on GitLab host")
+	}
+}
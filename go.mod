@@ -7,6 +7,7 @@ require (
 	github.com/go-git/go-git/v5 v5.5.1
 	github.com/mattn/go-isatty v0.0.16
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/mod v0.11.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -26,7 +27,6 @@ require (
 	github.com/skeema/knownhosts v1.1.0 // indirect
 	github.com/xanzy/ssh-agent v0.3.3 // indirect
 	golang.org/x/crypto v0.4.0 // indirect
-	golang.org/x/mod v0.7.0 // indirect
 	golang.org/x/net v0.4.0 // indirect
 	golang.org/x/sys v0.3.0 // indirect
 	golang.org/x/tools v0.4.0 // indirect
@@ -3,10 +3,12 @@ package main
 import (
 	"bufio"
 	"context"
-	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -30,6 +32,14 @@ type options struct {
 	noPR       bool
 	verbose    bool
 	clean      bool
+	dryRun     bool
+	resume     string
+	reportPath string
+	reportFmt  string
+	yes        bool
+	jobs       int
+
+	continueOnError bool
 }
 
 func main() {
@@ -49,6 +59,13 @@ func execute() error {
 	flag.BoolVar(&opts.noPR, "no-pr", false, "Prevents creating a Pull Request in the remote repo")
 	flag.BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
 	flag.BoolVar(&opts.clean, "clean", false, "Clean cannon cache directory")
+	flag.BoolVarP(&opts.yes, "yes", "y", false, "Skip the confirmation prompt, required if stdin is not a terminal")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "Show the changes that would be made to each repo without committing them")
+	flag.StringVar(&opts.resume, "resume", "", "Resume the interrupted run with the given ID")
+	flag.IntVarP(&opts.jobs, "jobs", "j", 0, "The maximum number of repos to operate on at once, overrides the default concurrency in the config")
+	flag.BoolVar(&opts.continueOnError, "continue-on-error", false, "Continue with the remaining repos if a repo fails")
+	flag.StringVar(&opts.reportPath, "report", "", "Write a report of the run to the given file")
+	flag.StringVar(&opts.reportFmt, "report-format", "", "The format of the report, either json or markdown (default inferred from the report file extension)")
 	flag.Parse()
 
 	level := log.LevelInfo
@@ -76,10 +93,47 @@ func execute() error {
 		return fmt.Errorf("failed to create cannon directory at %s: %w", cannonDir, err)
 	}
 
+	if opts.reportPath != "" {
+		// Validate the format now so the user doesn't find out after the run is done.
+		opts.reportFmt, err = reportFormat(opts.reportPath, opts.reportFmt)
+		if err != nil {
+			return err
+		}
+	}
+
 	conf, err := readConfig(opts.configPath)
 	if err != nil {
 		return err
 	}
+	// Listen of SIGINT to do a graceful abort
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	abort := make(chan os.Signal, 1)
+	signal.Notify(abort, os.Interrupt)
+	go func() {
+		<-abort
+		cancel()
+	}()
+
+	if conf.RepoQuery != nil {
+		logger.Infof("Finding repos in %s", conf.RepoQuery.Org)
+		if err := conf.resolveRepoQuery(ctx); err != nil {
+			return err
+		}
+	}
+	if len(conf.Repos) == 0 {
+		return errors.New("no repos to operate on")
+	}
+	if opts.jobs < 0 {
+		return errors.New("--jobs must not be negative")
+	}
+	if opts.jobs > 0 {
+		conf.Concurrency.Default = opts.jobs
+	}
+	conditions, err := action.ParseConditions(conf.Conditions)
+	if err != nil {
+		return fmt.Errorf("failed to parse conditions: %w", err)
+	}
 	actions := make([]action.Action, len(conf.Actions))
 	for i, c := range conf.Actions {
 		a, err := action.Parse(c)
@@ -89,164 +143,200 @@ func execute() error {
 		actions[i] = a
 	}
 
+	// Use a name that can't be a repo owner so it never conflicts with a cloned repo.
+	runsDir := filepath.Join(cannonDir, ".runs")
+	var state *runState
+	if opts.resume != "" {
+		if opts.dryRun {
+			return errors.New("cannot use --dry-run when resuming a run")
+		}
+		state, err = loadRunState(runsDir, opts.resume, conf.hash)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Show the actions that will be performed to the user and prompt for confirmation before proceeding.
+	if state != nil {
+		fmt.Printf("Resuming run %s\n\n", state.ID)
+	}
 	fmt.Println("Affected repos:")
-	for _, repo := range conf.Repos {
-		fmt.Printf("- %s\n", repo.Name)
+	for i, repo := range conf.Repos {
+		if state == nil {
+			fmt.Printf("- %s\n", repo.Name)
+			continue
+		}
+		rs := state.Repos[i]
+		if rs.Skipped || rs.Unchanged {
+			fmt.Printf("- %s (%s)\n", repo.Name, rs.status())
+		} else {
+			fmt.Printf("- %s (%s)\n", repo.Name, rs.Stage)
+		}
+	}
+	if len(conditions) > 0 {
+		fmt.Println("\nOnly repos where:")
+		for _, c := range conditions {
+			fmt.Printf("- %s\n", c)
+		}
 	}
 	fmt.Println("\nActions to perform:")
 	for _, a := range actions {
 		fmt.Printf("- %s\n\n", a)
 	}
-	// Read the user's response
-	fmt.Print("\nConfirm running with these parameters (y/n): ")
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
+	confirmed, err := confirmRun(ctx, opts, os.Stdin)
 	if err != nil {
-		return fmt.Errorf("failed to read user input: %w", err)
+		return err
 	}
-	// Support Y/y, everything else is no.
-	if strings.ToLower(strings.TrimSpace(input)) != "y" {
+	if !confirmed {
 		fmt.Println("Aborting")
 		return nil
 	}
 	fmt.Println()
 
-	// Listen of SIGINT to do a graceful abort
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	abort := make(chan os.Signal, 1)
-	signal.Notify(abort, os.Interrupt)
-	go func() {
-		<-abort
-		cancel()
-	}()
+	if state == nil {
+		// Dry runs don't need to be resumed so there is no need to persist the state.
+		dir := runsDir
+		if opts.dryRun {
+			dir = ""
+		}
+		state, err = newRunState(dir, conf.hash, conf.Branch, conf.Repos)
+		if err != nil {
+			return err
+		}
+		if !opts.dryRun {
+			logger.Infof("Starting run %s", state.ID)
+		}
+	}
 
 	tracker := &spinner.Tracker{
 		OutputLogger:    logger,
 		PersistMessages: opts.verbose,
 	}
 	ctx = progress.ContextWithTracker(ctx, tracker)
-	// Create a random number suffix so each branch is unique.
-	b := make([]byte, 8)
-	if _, err := rand.Read(b); err != nil {
-		return fmt.Errorf("failed to generate random branch number: %w", err)
+	r := runner{
+		opts:       opts,
+		conf:       conf,
+		actions:    actions,
+		conditions: conditions,
+		state:      state,
+		cannonDir:  cannonDir,
+		logger:     logger,
+		out:        os.Stdout,
 	}
-	newBranch := "cannon/change-" + hex.EncodeToString(b)
-
-	repos, err := progress.RunParallelT(ctx, progress.RunParallelOptions{
-		Message: "Preparing repos",
-		Count:   len(conf.Repos),
-	}, func(ctx context.Context, i int) (*git.Repository, error) {
-		r := conf.Repos[i]
-		tracker := progress.TrackerFromContext(ctx)
-		tracker.Debugf("Preparing repo %s", r.Name)
-
-		repo, err := git.Prepare(ctx, r.Name, cannonDir, r.Base)
-		if err != nil {
-			return nil, err
-		}
-		if err := repo.CreateBranch(newBranch); err != nil {
-			return nil, err
+	runErr := r.run(ctx)
+	// Always write the report, it is most useful when something failed.
+	if opts.reportPath != "" {
+		if err := writeReport(opts.reportPath, opts.reportFmt, state); err != nil {
+			if runErr == nil {
+				return err
+			}
+			logger.Errorf("%s", err)
 		}
-		return repo, nil
-	})
-	if err != nil {
-		return fmt.Errorf("failed to prepare repos: %w", err)
 	}
-
-	repoMsgs, err := progress.RunParallelT(ctx, progress.RunParallelOptions{
-		Message:       "Running actions on repos",
-		Count:         len(repos),
-		CancelOnError: true,
-	}, func(ctx context.Context, i int) ([]string, error) {
-		repo := repos[i]
-
-		tracker := progress.TrackerFromContext(ctx)
-		tracker.Debugf("Running actions on repo %s", repo.Name())
-
-		parts := strings.Split(repo.Name(), "/")
-		// Variables that will be shared across all actions
-		vars := map[string]string{
-			"REPO_OWNER": parts[0],
-			"REPO_NAME":  parts[1],
+	if runErr != nil {
+		if !opts.dryRun {
+			fmt.Fprintf(os.Stderr, "\nThe run can be resumed with: cannon --resume %s\n", state.ID)
 		}
-		msgs := make([]string, len(actions))
-		for j, a := range actions {
-			msg, err := a.Run(ctx, repo, action.Arguments{Variables: vars})
-			if err != nil {
-				return nil, err
-			}
-			msgs[j] = msg
-		}
-		return msgs, nil
-	})
-	if err != nil {
-		return fmt.Errorf("failed to perform actions on repos: %w", err)
+		return runErr
 	}
+	return nil
+}
 
-	err = progress.RunParallel(ctx, progress.RunParallelOptions{
-		Message:       "Committing changes to repos",
-		Count:         len(repos),
-		CancelOnError: true,
-	}, func(ctx context.Context, i int) error {
-		repo := repos[i]
-		tracker := progress.TrackerFromContext(ctx)
-		tracker.Debugf("Committing changes to repo %s", repo.Name())
-		return repo.CommitChanges(ctx, opts.commitMsg)
-	})
-	if err != nil {
-		return fmt.Errorf("failed to commit changes to repos: %w", err)
+// confirmRun prompts the user to confirm the run on stdin. It reports whether the run
+// should proceed. No prompt is shown if opts skip the confirmation.
+func confirmRun(ctx context.Context, opts options, stdin *os.File) (bool, error) {
+	// A dry run never commits or pushes anything so there is no need to confirm.
+	if opts.dryRun || opts.yes {
+		return true, nil
 	}
-
-	logger.Info("Changes applied")
-	if opts.noPush {
-		return nil
+	// Fail instead of aborting if we can't prompt, otherwise it looks like
+	// nothing went wrong when running somewhere like CI.
+	if !isatty.IsTerminal(stdin.Fd()) && !isatty.IsCygwinTerminal(stdin.Fd()) {
+		return false, errors.New("unable to prompt for confirmation since stdin is not a terminal, use --yes to run without confirmation")
 	}
-
-	prURLs, err := progress.RunParallelT(ctx, progress.RunParallelOptions{
-		Message: "Pushing changes to GitHub",
-		Count:   len(repos),
-	}, func(ctx context.Context, i int) (string, error) {
-		repo := repos[i]
-		tracker := progress.TrackerFromContext(ctx)
-		tracker.Debugf("Pushing changes for repo %s", repo.Name())
-
-		if err := repo.Push(ctx); err != nil {
-			return "", err
-		}
-		if opts.noPR {
-			return git.CreatePRURL(repo.Name(), newBranch), nil
-		}
-
-		tracker.Debugf("Creating PR for repo %s", repo.Name())
-		var desc strings.Builder
-		desc.WriteString("Changes applied by commit-cannon:\n")
-		for _, m := range repoMsgs[i] {
-			desc.WriteString("  * ")
-			desc.WriteString(m)
-			desc.WriteByte('\n')
-		}
-		return repo.CreatePR(ctx, newBranch, desc.String())
-	})
+	// Read the user's response
+	fmt.Print("\nConfirm running with these parameters (y/n): ")
+	input, err := readLine(ctx, stdin)
 	if err != nil {
-		return fmt.Errorf("failed to push changes to repos: %w", err)
+		return false, fmt.Errorf("failed to read user input: %w", err)
+	}
+	// Support Y/y, everything else is no.
+	return strings.ToLower(strings.TrimSpace(input)) == "y", nil
+}
+
+// readLine reads a line from r. Since SIGINT is handled, it returns
+// early if ctx is cancelled so the user can still abort at a prompt.
+func readLine(ctx context.Context, r io.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
 	}
-	fmt.Println("Pull Request URLs:")
-	for i, repo := range repos {
-		fmt.Printf("- %s: %s\n", repo.Name(), prURLs[i])
+	ch := make(chan result, 1)
+	go func() {
+		line, err := bufio.NewReader(r).ReadString('\n')
+		ch <- result{line, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-ch:
+		return res.line, res.err
 	}
-	return nil
 }
 
 type config struct {
-	Repos   []repoConfig    `yaml:"repos"`
-	Actions []action.Config `yaml:"actions"`
+	Repos []repoConfig `yaml:"repos"`
+	// RepoQuery finds more repos to operate on in addition to Repos.
+	RepoQuery *repoQueryConfig `yaml:"repoQuery"`
+	// Conditions are used to skip repos that they don't match.
+	Conditions action.ConditionConfig `yaml:",inline"`
+	Actions    []action.Config        `yaml:"actions"`
+	// Branch is the name of the branch to commit changes to. If it is set, the same branch
+	// is reused across runs and any existing PR for it is updated. Otherwise a unique
+	// branch is created for each run.
+	Branch      string            `yaml:"branch"`
+	Concurrency concurrencyConfig `yaml:"concurrency"`
+	// Host is the default host for all repos. Defaults to github.com.
+	Host        git.Host          `yaml:"host"`
+	PullRequest pullRequestConfig `yaml:"pullRequest"`
+
+	hash string // hash of the repos and actions; used to identify the config a run used
 }
 
 type repoConfig struct {
 	Name string `yaml:"name"`
 	Base string `yaml:"base"`
+	// Host overrides the default host for this repo.
+	// Any fields that are not set use the values from the default host.
+	Host git.Host `yaml:"host"`
+}
+
+// repoQueryConfig finds repos to operate on using the GitHub API.
+type repoQueryConfig struct {
+	git.RepoQuery `yaml:",inline"`
+	// Base is the base branch for PRs. Defaults to the default branch of each repo.
+	Base string `yaml:"base"`
+}
+
+// concurrencyConfig limits how many repos are operated on at once during each stage.
+// A value of 0 means no limit is set.
+type concurrencyConfig struct {
+	// Default is the limit for all stages that don't have their own limit.
+	Default int `yaml:"default"`
+	// Prepare is the limit for cloning and updating repos.
+	Prepare int `yaml:"prepare"`
+	// Actions is the limit for running actions, which may include running commands.
+	Actions int `yaml:"actions"`
+	// Push is the limit for pushing changes and creating PRs.
+	Push int `yaml:"push"`
+}
+
+// limit returns the concurrency limit to use for a stage with the given configured limit.
+func (c concurrencyConfig) limit(stageLimit int) uint {
+	if stageLimit > 0 {
+		return uint(stageLimit)
+	}
+	return uint(c.Default)
 }
 
 func readConfig(configPath string) (config, error) {
@@ -268,6 +358,67 @@ func readConfig(configPath string) (config, error) {
 		if rc.Base == "" {
 			conf.Repos[i].Base = "master"
 		}
+		host, err := rc.Host.Merge(conf.Host).WithDefaults()
+		if err != nil {
+			return conf, fmt.Errorf("invalid host for repo %s: %w", rc.Name, err)
+		}
+		conf.Repos[i].Host = host
+	}
+	c := conf.Concurrency
+	if c.Default < 0 || c.Prepare < 0 || c.Actions < 0 || c.Push < 0 {
+		return conf, errors.New("concurrency limits must not be negative")
+	}
+	if err := conf.PullRequest.parse(); err != nil {
+		return conf, err
+	}
+	if err := conf.setHash(); err != nil {
+		return conf, err
 	}
 	return conf, nil
 }
+
+// setHash sets the hash of the config. It must be called whenever the repos or actions change.
+func (c *config) setHash() error {
+	// Only hash the repos, conditions and actions since they determine the changes a run makes.
+	// This allows other settings, like concurrency, to be changed when resuming a run.
+	data, err := json.Marshal(struct {
+		Repos      []repoConfig
+		Conditions action.ConditionConfig
+		Actions    []action.Config
+	}{c.Repos, c.Conditions, c.Actions})
+	if err != nil {
+		return fmt.Errorf("failed to hash config: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	c.hash = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// resolveRepoQuery adds the repos found by the repo query to the list of repos.
+// Repos that are already listed are skipped, so they can be used to override
+// the config of individual repos.
+func (c *config) resolveRepoQuery(ctx context.Context) error {
+	host, err := c.Host.WithDefaults()
+	if err != nil {
+		return fmt.Errorf("invalid host: %w", err)
+	}
+	found, err := git.FindRepos(ctx, host, c.RepoQuery.RepoQuery)
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]bool, len(c.Repos))
+	for _, rc := range c.Repos {
+		listed[rc.Name] = true
+	}
+	for _, r := range found {
+		if listed[r.Name] {
+			continue
+		}
+		base := c.RepoQuery.Base
+		if base == "" {
+			base = r.DefaultBranch
+		}
+		c.Repos = append(c.Repos, repoConfig{Name: r.Name, Base: base, Host: host})
+	}
+	return c.setHash()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConfirmRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options
+		want    bool
+		wantErr string
+	}{
+		{name: "yes", opts: options{yes: true}, want: true},
+		{name: "dry run", opts: options{dryRun: true}, want: true},
+		{name: "stdin not a terminal", opts: options{}, wantErr: "use --yes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A pipe is never a terminal, like stdin in CI.
+			stdin, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("failed to create pipe: %v", err)
+			}
+			defer stdin.Close()
+			// Close the write end so reading fails instead of blocking if a prompt is shown.
+			w.Close()
+
+			got, err := confirmRun(context.Background(), tt.opts, stdin)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got confirmed %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
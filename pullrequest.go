@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/TouchBistro/cannon/action"
+	"github.com/TouchBistro/cannon/git"
+)
+
+// pullRequestConfig configures the PRs that are created for each repo.
+type pullRequestConfig struct {
+	// Title is a template for the title of the PR. Defaults to the name of the branch.
+	Title string `yaml:"title"`
+	// Body is a template for the description of the PR.
+	// Defaults to a list of the changes made by the actions.
+	Body string `yaml:"body"`
+	// Labels are added to the PR.
+	Labels []string `yaml:"labels"`
+	// Reviewers are users to request a review from.
+	Reviewers []string `yaml:"reviewers"`
+	// TeamReviewers are teams to request a review from.
+	TeamReviewers []string `yaml:"teamReviewers"`
+	// Assignees are users to assign the PR to.
+	Assignees []string `yaml:"assignees"`
+	// Draft creates the PR as a draft.
+	Draft bool `yaml:"draft"`
+
+	titleTmpl *template.Template
+	bodyTmpl  *template.Template
+}
+
+// prTemplateData is the data available to the PR title and body templates.
+type prTemplateData struct {
+	// Repo is the full name of the repo.
+	Repo string
+	// Branch is the branch containing the changes.
+	Branch string
+	// Base is the branch the changes will be merged into.
+	Base string
+	// Vars are the variables that were available to the actions, e.g. REPO_NAME.
+	Vars map[string]string
+	// Results are the results of each action that was run on the repo.
+	Results []action.Result
+	// Description is the default description of the PR.
+	Description string
+}
+
+// parse parses the title and body templates so errors are found before the run starts.
+func (c *pullRequestConfig) parse() error {
+	var err error
+	if c.Title != "" {
+		c.titleTmpl, err = template.New("title").Option("missingkey=error").Parse(c.Title)
+		if err != nil {
+			return fmt.Errorf("failed to parse pull request title template: %w", err)
+		}
+	}
+	if c.Body != "" {
+		c.bodyTmpl, err = template.New("body").Option("missingkey=error").Parse(c.Body)
+		if err != nil {
+			return fmt.Errorf("failed to parse pull request body template: %w", err)
+		}
+	}
+	return nil
+}
+
+// pullRequest returns the PR to create using data to render the templates.
+func (c pullRequestConfig) pullRequest(data prTemplateData) (git.PullRequest, error) {
+	pr := git.PullRequest{
+		Head:          data.Branch,
+		Title:         data.Branch,
+		Body:          data.Description,
+		Labels:        c.Labels,
+		Reviewers:     c.Reviewers,
+		TeamReviewers: c.TeamReviewers,
+		Assignees:     c.Assignees,
+		Draft:         c.Draft,
+	}
+	var sb strings.Builder
+	if c.titleTmpl != nil {
+		if err := c.titleTmpl.Execute(&sb, data); err != nil {
+			return pr, fmt.Errorf("failed to render pull request title for repo %s: %w", data.Repo, err)
+		}
+		// A title can only be a single line.
+		pr.Title = strings.TrimSpace(sb.String())
+		sb.Reset()
+	}
+	if c.bodyTmpl != nil {
+		if err := c.bodyTmpl.Execute(&sb, data); err != nil {
+			return pr, fmt.Errorf("failed to render pull request body for repo %s: %w", data.Repo, err)
+		}
+		pr.Body = sb.String()
+	}
+	return pr, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+// Supported report formats.
+const (
+	reportFormatJSON     = "json"
+	reportFormatMarkdown = "markdown"
+)
+
+// reportFormat returns the format to use for the report at path.
+// If format is empty, it is inferred from the file extension.
+func reportFormat(path, format string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			return reportFormatJSON, nil
+		case ".md", ".markdown":
+			return reportFormatMarkdown, nil
+		default:
+			return "", fmt.Errorf("unable to determine report format from file %s, set the format explicitly", path)
+		}
+	}
+	switch format {
+	case reportFormatJSON, reportFormatMarkdown:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported report format %s", format)
+	}
+}
+
+// report is a machine readable summary of a run.
+type report struct {
+	RunID      string       `json:"runId"`
+	ConfigHash string       `json:"configHash"`
+	Branch     string       `json:"branch"`
+	Repos      []repoReport `json:"repos"`
+}
+
+// repoReport is the outcome of a run for a single repo.
+type repoReport struct {
+	Name    string          `json:"name"`
+	Stage   stage           `json:"stage"`
+	Status  string          `json:"status"`
+	Commit  string          `json:"commit,omitempty"`
+	PRURL   string          `json:"prURL,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Results []action.Result `json:"results"`
+}
+
+func newReport(state *runState) report {
+	rep := report{
+		RunID:      state.ID,
+		ConfigHash: state.ConfigHash,
+		Branch:     state.Branch,
+		Repos:      make([]repoReport, len(state.Repos)),
+	}
+	for i, rs := range state.Repos {
+		rep.Repos[i] = repoReport{
+			Name:    rs.Name,
+			Stage:   rs.Stage,
+			Status:  rs.status(),
+			Commit:  rs.Commit,
+			PRURL:   rs.PRURL,
+			Error:   rs.Error,
+			Results: rs.Results,
+		}
+	}
+	return rep
+}
+
+// writeReport writes a report of the run to path in the given format.
+func writeReport(path, format string, state *runState) error {
+	rep := newReport(state)
+	var data []byte
+	switch format {
+	case reportFormatJSON:
+		var err error
+		data, err = json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report as JSON: %w", err)
+		}
+		data = append(data, '\n')
+	case reportFormatMarkdown:
+		data = []byte(rep.markdown())
+	default:
+		panic("impossible: invalid report format")
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write report to %s: %w", path, err)
+	}
+	return nil
+}
+
+// markdown renders the report as a markdown document.
+func (rep report) markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Cannon run `%s`\n\n", rep.RunID)
+	fmt.Fprintf(&sb, "- **Branch:** `%s`\n", rep.Branch)
+	fmt.Fprintf(&sb, "- **Config hash:** `%s`\n\n", rep.ConfigHash)
+
+	sb.WriteString("| Repo | Stage | Status | Commit | Pull Request |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, rr := range rep.Repos {
+		commit := rr.Commit
+		if commit != "" {
+			commit = "`" + commit + "`"
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", rr.Name, rr.Stage, rr.Status, commit, rr.PRURL)
+	}
+
+	for _, rr := range rep.Repos {
+		fmt.Fprintf(&sb, "\n## %s\n\n", rr.Name)
+		if rr.Error != "" {
+			fmt.Fprintf(&sb, "**Error:**\n\n```\n%s\n```\n\n", rr.Error)
+		}
+		if len(rr.Results) == 0 {
+			sb.WriteString("No actions were run.\n")
+			continue
+		}
+		for _, res := range rr.Results {
+			sb.WriteString("- ")
+			sb.WriteString(res.Message)
+			if !res.Changed {
+				sb.WriteString(" (no changes)")
+			}
+			sb.WriteByte('\n')
+			if res.Detail != "" {
+				for _, line := range strings.Split(res.Detail, "\n") {
+					sb.WriteString("  ")
+					sb.WriteString(line)
+					sb.WriteByte('\n')
+				}
+			}
+		}
+	}
+	return sb.String()
+}
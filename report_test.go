@@ -0,0 +1,185 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+// reportState is a run where each repo ended up in a different state.
+var reportState = &runState{
+	ID:         "a1b2c3d4",
+	Branch:     "cannon/change-a1b2c3d4",
+	ConfigHash: "5f3e2a",
+	Repos: []*repoState{
+		{
+			Name:  "TouchBistro/hype",
+			Stage: stagePRCreated,
+			Results: []action.Result{
+				{Message: "Bumped `express` to `4.18.2`", Detail: "* `package.json`: `^4.17.1` → `^4.18.2`\n* `package.json`: `~4.17.1` → `~4.18.2`", Files: []string{"package.json"}, Matches: 2, Changed: true},
+				{Message: "Set `version` to `1.1.0` in `package.json`", Files: []string{"package.json"}, Matches: 1},
+			},
+			Commit: "d2c8a3f",
+			PRURL:  "https://github.com/TouchBistro/hype/pull/1",
+		},
+		{
+			Name:  "TouchBistro/woke",
+			Stage: stageCommitted,
+			Results: []action.Result{
+				{Message: "Replaced `1.0.0` with `1.1.0` in `VERSION`", Files: []string{"VERSION"}, Matches: 1, Changed: true},
+			},
+			Commit: "9e1f0b7",
+			Error:  "failed to push branch: remote hung up",
+		},
+		{
+			Name:    "TouchBistro/lit",
+			Stage:   stagePrepared,
+			Skipped: true,
+		},
+	},
+}
+
+const wantReportJSON = `{
+  "runId": "a1b2c3d4",
+  "configHash": "5f3e2a",
+  "branch": "cannon/change-a1b2c3d4",
+  "repos": [
+    {
+      "name": "TouchBistro/hype",
+      "stage": "pr created",
+      "status": "ok",
+      "commit": "d2c8a3f",
+      "prURL": "https://github.com/TouchBistro/hype/pull/1",
+      "results": [
+        {
+          "message": "Bumped ` + "`express`" + ` to ` + "`4.18.2`" + `",
+          "detail": "* ` + "`package.json`" + `: ` + "`^4.17.1`" + ` → ` + "`^4.18.2`" + `\n* ` + "`package.json`" + `: ` + "`~4.17.1`" + ` → ` + "`~4.18.2`" + `",
+          "files": [
+            "package.json"
+          ],
+          "matches": 2,
+          "changed": true
+        },
+        {
+          "message": "Set ` + "`version`" + ` to ` + "`1.1.0`" + ` in ` + "`package.json`" + `",
+          "files": [
+            "package.json"
+          ],
+          "matches": 1,
+          "changed": false
+        }
+      ]
+    },
+    {
+      "name": "TouchBistro/woke",
+      "stage": "committed",
+      "status": "failed",
+      "commit": "9e1f0b7",
+      "error": "failed to push branch: remote hung up",
+      "results": [
+        {
+          "message": "Replaced ` + "`1.0.0`" + ` with ` + "`1.1.0`" + ` in ` + "`VERSION`" + `",
+          "files": [
+            "VERSION"
+          ],
+          "matches": 1,
+          "changed": true
+        }
+      ]
+    },
+    {
+      "name": "TouchBistro/lit",
+      "stage": "prepared",
+      "status": "skipped",
+      "results": null
+    }
+  ]
+}
+`
+
+const wantReportMarkdown = "# Cannon run `a1b2c3d4`\n" +
+	"\n" +
+	"- **Branch:** `cannon/change-a1b2c3d4`\n" +
+	"- **Config hash:** `5f3e2a`\n" +
+	"\n" +
+	"| Repo | Stage | Status | Commit | Pull Request |\n" +
+	"| --- | --- | --- | --- | --- |\n" +
+	"| TouchBistro/hype | pr created | ok | `d2c8a3f` | https://github.com/TouchBistro/hype/pull/1 |\n" +
+	"| TouchBistro/woke | committed | failed | `9e1f0b7` |  |\n" +
+	"| TouchBistro/lit | prepared | skipped |  |  |\n" +
+	"\n" +
+	"## TouchBistro/hype\n" +
+	"\n" +
+	"- Bumped `express` to `4.18.2`\n" +
+	"  * `package.json`: `^4.17.1` → `^4.18.2`\n" +
+	"  * `package.json`: `~4.17.1` → `~4.18.2`\n" +
+	"- Set `version` to `1.1.0` in `package.json` (no changes)\n" +
+	"\n" +
+	"## TouchBistro/woke\n" +
+	"\n" +
+	"**Error:**\n" +
+	"\n" +
+	"```\n" +
+	"failed to push branch: remote hung up\n" +
+	"```\n" +
+	"\n" +
+	"- Replaced `1.0.0` with `1.1.0` in `VERSION`\n" +
+	"\n" +
+	"## TouchBistro/lit\n" +
+	"\n" +
+	"No actions were run.\n"
+
+func TestWriteReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		format string
+		want   string
+	}{
+		{name: "JSON", path: "report.json", want: wantReportJSON},
+		{name: "markdown", path: "report.md", want: wantReportMarkdown},
+		{name: "explicit format", path: "report.txt", format: reportFormatMarkdown, want: wantReportMarkdown},
+	}
+
+	td := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := reportFormat(tt.path, tt.format)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			path := filepath.Join(td, tt.path)
+			if err := writeReport(path, format, reportState); err != nil {
+				t.Fatalf("failed to write report: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read report: %v", err)
+			}
+			if got := string(data); got != tt.want {
+				t.Errorf("got report\n\t%s\nwant\n\t%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportFormatError(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		format string
+	}{
+		{name: "unknown extension", path: "report.txt"},
+		{name: "unsupported format", path: "report.json", format: "html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := reportFormat(tt.path, tt.format); err == nil {
+				t.Error("want non-nil error", err)
+			}
+		})
+	}
+}
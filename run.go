@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/TouchBistro/cannon/action"
+	"github.com/TouchBistro/cannon/git"
+	"github.com/TouchBistro/goutils/log"
+	"github.com/TouchBistro/goutils/progress"
+	"github.com/mattn/go-isatty"
+)
+
+// runner applies the actions to each repo and tracks the progress of the run.
+type runner struct {
+	opts       options
+	conf       config
+	actions    []action.Action
+	conditions []action.Condition
+	state      *runState
+	cannonDir  string
+	logger     *log.Logger
+	out        io.Writer // where diffs and the summary are printed
+	// repos[i] is the repo for conf.Repos[i]. It is nil if the repo
+	// does not need to be operated on during this run.
+	repos []*git.Repository
+}
+
+// run runs each stage on all the repos that have not completed it yet.
+func (r *runner) run(ctx context.Context) error {
+	r.repos = make([]*git.Repository, len(r.conf.Repos))
+	// Give repos that failed in a previous attempt another chance.
+	if err := r.state.clearErrors(); err != nil {
+		return err
+	}
+
+	if err := r.prepare(ctx); err != nil {
+		return err
+	}
+	if err := r.filter(ctx); err != nil {
+		return err
+	}
+	if err := r.runActions(ctx); err != nil {
+		return err
+	}
+	if r.opts.dryRun {
+		return r.showDiffs(ctx)
+	}
+	if err := r.commit(ctx); err != nil {
+		return err
+	}
+	r.logger.Info("Changes applied")
+	if !r.opts.noPush {
+		if err := r.push(ctx); err != nil {
+			return err
+		}
+	}
+	r.printSummary()
+	if failed := r.state.selectRepos(func(rs *repoState) bool { return rs.Error != "" }); len(failed) > 0 {
+		return fmt.Errorf("%d of %d repos failed", len(failed), len(r.state.Repos))
+	}
+	return nil
+}
+
+// runStage calls fn concurrently for each repo selected by pred. Repos that
+// have failed are never selected. If fn returns an error, it is recorded in the
+// repo's state. If continue on error is enabled, the error is not returned so that
+// the remaining repos can continue through the stage. Otherwise it is returned.
+func (r *runner) runStage(
+	ctx context.Context,
+	opts progress.RunParallelOptions,
+	pred func(rs *repoState) bool,
+	fn func(ctx context.Context, ri int) error,
+) error {
+	indices := r.state.selectRepos(func(rs *repoState) bool {
+		return rs.Error == "" && pred(rs)
+	})
+	if len(indices) == 0 {
+		return nil
+	}
+	opts.Count = len(indices)
+	if r.opts.continueOnError {
+		opts.CancelOnError = false
+	}
+	return progress.RunParallel(ctx, opts, func(ctx context.Context, i int) error {
+		ri := indices[i]
+		err := fn(ctx, ri)
+		if err == nil {
+			return nil
+		}
+		rs := r.state.Repos[ri]
+		if uerr := r.state.update(func() { rs.Error = err.Error() }); uerr != nil {
+			return uerr
+		}
+		// Always stop if the run was cancelled, there's no point continuing.
+		if r.opts.continueOnError && !errors.Is(err, context.Canceled) {
+			progress.TrackerFromContext(ctx).Warnf("Repo %s failed: %s", rs.Name, err)
+			return nil
+		}
+		return err
+	})
+}
+
+// prepare gets all repos ready to be operated on. Repos that have not been committed yet
+// are cleaned and put on a fresh branch. Repos that have been committed but not yet
+// had a PR created are opened as is so the remaining stages can be completed.
+func (r *runner) prepare(ctx context.Context) error {
+	err := r.runStage(ctx, progress.RunParallelOptions{
+		Message:     "Preparing repos",
+		Concurrency: r.conf.Concurrency.limit(r.conf.Concurrency.Prepare),
+	}, func(rs *repoState) bool {
+		return !rs.Skipped && !rs.Unchanged && rs.Stage < stagePRCreated
+	}, func(ctx context.Context, ri int) error {
+		rc := r.conf.Repos[ri]
+		rs := r.state.Repos[ri]
+		tracker := progress.TrackerFromContext(ctx)
+
+		if rs.Stage >= stageCommitted {
+			tracker.Debugf("Opening repo %s to resume from stage %s", rc.Name, rs.Stage)
+			repo, err := git.Open(rc.Name, r.cannonDir, rc.Base, rc.Host)
+			if err != nil {
+				return err
+			}
+			if err := repo.CheckoutBranch(r.state.Branch); err != nil {
+				return err
+			}
+			r.repos[ri] = repo
+			return nil
+		}
+
+		tracker.Debugf("Preparing repo %s", rc.Name)
+		repo, err := git.Prepare(ctx, rc.Name, r.cannonDir, rc.Base, rc.Host)
+		if err != nil {
+			return err
+		}
+		// A branch from the config is reused by every run so it may already exist on the remote.
+		// Generated branches are unique to the run so there is nothing to fetch.
+		if r.conf.Branch != "" {
+			if err := repo.FetchBranch(ctx, r.state.Branch); err != nil {
+				return err
+			}
+		}
+		if err := repo.CreateBranch(r.state.Branch); err != nil {
+			return err
+		}
+		r.repos[ri] = repo
+		return r.state.update(func() {
+			rs.Stage = stagePrepared
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare repos: %w", err)
+	}
+	return nil
+}
+
+// filter checks the conditions for each prepared repo. Repos that don't
+// satisfy all the conditions are skipped and are not operated on any further.
+func (r *runner) filter(ctx context.Context) error {
+	if len(r.conditions) == 0 {
+		return nil
+	}
+	err := r.runStage(ctx, progress.RunParallelOptions{
+		Message:       "Checking conditions for repos",
+		Concurrency:   r.conf.Concurrency.limit(r.conf.Concurrency.Actions),
+		CancelOnError: true,
+	}, func(rs *repoState) bool {
+		return rs.Stage == stagePrepared && !rs.Skipped
+	}, func(ctx context.Context, ri int) error {
+		repo := r.repos[ri]
+		tracker := progress.TrackerFromContext(ctx)
+		args := action.Arguments{Variables: repoVariables(repo.Name())}
+		for _, c := range r.conditions {
+			ok, err := c.Match(ctx, repo, args)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				tracker.Debugf("Skipping repo %s, condition not satisfied: %s", repo.Name(), c)
+				rs := r.state.Repos[ri]
+				return r.state.update(func() {
+					rs.Skipped = true
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check conditions for repos: %w", err)
+	}
+	return nil
+}
+
+// runActions runs all actions on each prepared repo.
+func (r *runner) runActions(ctx context.Context) error {
+	err := r.runStage(ctx, progress.RunParallelOptions{
+		Message:       "Running actions on repos",
+		Concurrency:   r.conf.Concurrency.limit(r.conf.Concurrency.Actions),
+		CancelOnError: true,
+	}, func(rs *repoState) bool {
+		return rs.Stage == stagePrepared && !rs.Skipped
+	}, func(ctx context.Context, ri int) error {
+		repo := r.repos[ri]
+		tracker := progress.TrackerFromContext(ctx)
+		tracker.Debugf("Running actions on repo %s", repo.Name())
+
+		// Variables that will be shared across all actions
+		vars := repoVariables(repo.Name())
+		results := make([]action.Result, len(r.actions))
+		for j, a := range r.actions {
+			res, err := a.Run(ctx, repo, action.Arguments{Variables: vars})
+			if err != nil {
+				return err
+			}
+			results[j] = res
+		}
+		rs := r.state.Repos[ri]
+		return r.state.update(func() {
+			rs.Stage = stageActionsRun
+			rs.Results = results
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to perform actions on repos: %w", err)
+	}
+	return nil
+}
+
+// showDiffs prints the changes the actions made to each repo and then discards them.
+func (r *runner) showDiffs(ctx context.Context) error {
+	color := isatty.IsTerminal(os.Stdout.Fd())
+	diffs := make([]string, len(r.repos))
+	err := r.runStage(ctx, progress.RunParallelOptions{
+		Message:     "Computing changes to repos",
+		Concurrency: r.conf.Concurrency.limit(0),
+	}, func(rs *repoState) bool {
+		return rs.Stage == stageActionsRun
+	}, func(ctx context.Context, ri int) error {
+		repo := r.repos[ri]
+		tracker := progress.TrackerFromContext(ctx)
+		tracker.Debugf("Computing diff for repo %s", repo.Name())
+
+		diff, err := repo.Diff(ctx, color)
+		if err != nil {
+			return err
+		}
+		// Discard the changes so the repo is left as it was before the actions were run.
+		if err := repo.Reset(); err != nil {
+			return err
+		}
+		diffs[ri] = diff
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compute changes to repos: %w", err)
+	}
+	for ri, rs := range r.state.Repos {
+		fmt.Fprintf(r.out, "==> %s\n", rs.Name)
+		switch {
+		case rs.Error != "":
+			fmt.Fprintf(r.out, "Failed: %s\n\n", rs.Error)
+		case rs.Skipped:
+			fmt.Fprint(r.out, "Skipped\n\n")
+		case diffs[ri] == "":
+			fmt.Fprint(r.out, "No changes\n\n")
+		default:
+			fmt.Fprintln(r.out, diffs[ri])
+		}
+	}
+	return nil
+}
+
+// commit commits the changes made by the actions. Repos where the actions
+// did not result in any changes are marked as unchanged and are not committed
+// since there is nothing to create a PR for.
+func (r *runner) commit(ctx context.Context) error {
+	err := r.runStage(ctx, progress.RunParallelOptions{
+		Message:       "Committing changes to repos",
+		Concurrency:   r.conf.Concurrency.limit(0),
+		CancelOnError: true,
+	}, func(rs *repoState) bool {
+		return rs.Stage == stageActionsRun && !rs.Unchanged
+	}, func(ctx context.Context, ri int) error {
+		repo := r.repos[ri]
+		rs := r.state.Repos[ri]
+		tracker := progress.TrackerFromContext(ctx)
+		hasChanges, err := repo.HasChanges(ctx)
+		if err != nil {
+			return err
+		}
+		if !hasChanges {
+			tracker.Debugf("No changes in repo %s, skipping commit", repo.Name())
+			return r.state.update(func() {
+				rs.Unchanged = true
+			})
+		}
+		tracker.Debugf("Committing changes to repo %s", repo.Name())
+		hash, err := repo.CommitChanges(ctx, r.opts.commitMsg)
+		if err != nil {
+			return err
+		}
+		return r.state.update(func() {
+			rs.Stage = stageCommitted
+			rs.Commit = hash
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit changes to repos: %w", err)
+	}
+	return nil
+}
+
+// push pushes the committed changes and creates a PR for each repo.
+func (r *runner) push(ctx context.Context) error {
+	err := r.runStage(ctx, progress.RunParallelOptions{
+		Message:     "Pushing changes and creating PRs",
+		Concurrency: r.conf.Concurrency.limit(r.conf.Concurrency.Push),
+	}, func(rs *repoState) bool {
+		return rs.Stage == stageCommitted || rs.Stage == stagePushed
+	}, func(ctx context.Context, ri int) error {
+		repo := r.repos[ri]
+		rs := r.state.Repos[ri]
+		tracker := progress.TrackerFromContext(ctx)
+
+		if rs.Stage < stagePushed {
+			tracker.Debugf("Pushing changes for repo %s", repo.Name())
+			if err := repo.Push(ctx); err != nil {
+				return err
+			}
+			err := r.state.update(func() {
+				rs.Stage = stagePushed
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if r.opts.noPR {
+			return r.state.update(func() {
+				rs.PRURL = repo.CreatePRURL(r.state.Branch)
+			})
+		}
+
+		tracker.Debugf("Creating PR for repo %s", repo.Name())
+		pr, err := r.conf.PullRequest.pullRequest(prTemplateData{
+			Repo:        repo.Name(),
+			Branch:      r.state.Branch,
+			Base:        r.conf.Repos[ri].Base,
+			Vars:        repoVariables(repo.Name()),
+			Results:     rs.Results,
+			Description: prDescription(rs.Results),
+		})
+		if err != nil {
+			return err
+		}
+		prURL, err := repo.CreatePR(ctx, pr)
+		if err != nil {
+			if prURL != "" {
+				// The PR was created but couldn't be fully updated, keep track of it
+				// so the user knows where it is.
+				if uerr := r.state.update(func() { rs.PRURL = prURL }); uerr != nil {
+					return uerr
+				}
+			}
+			return err
+		}
+		return r.state.update(func() {
+			rs.Stage = stagePRCreated
+			rs.PRURL = prURL
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to push changes to repos: %w", err)
+	}
+	return nil
+}
+
+// printSummary prints the outcome of the run for each repo.
+func (r *runner) printSummary() {
+	var changed, pushed, unchanged, skipped []*repoState
+	for _, rs := range r.state.Repos {
+		switch {
+		case rs.Error != "":
+			continue
+		case rs.Skipped:
+			skipped = append(skipped, rs)
+		case rs.Unchanged:
+			unchanged = append(unchanged, rs)
+		case rs.Stage >= stagePushed:
+			pushed = append(pushed, rs)
+		case rs.Stage == stageCommitted:
+			changed = append(changed, rs)
+		}
+	}
+	if len(changed) > 0 {
+		fmt.Fprintln(r.out, "Changed repos:")
+		for _, rs := range changed {
+			fmt.Fprintf(r.out, "- %s (%s)\n", rs.Name, summarizeResults(rs.Results))
+		}
+	}
+	if len(pushed) > 0 {
+		fmt.Fprintln(r.out, "Pull Request URLs:")
+		for _, rs := range pushed {
+			fmt.Fprintf(r.out, "- %s: %s (%s)\n", rs.Name, rs.PRURL, summarizeResults(rs.Results))
+		}
+	}
+	if len(unchanged) > 0 {
+		fmt.Fprintln(r.out, "Unchanged repos:")
+		for _, rs := range unchanged {
+			fmt.Fprintf(r.out, "- %s\n", rs.Name)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintln(r.out, "Skipped repos:")
+		for _, rs := range skipped {
+			fmt.Fprintf(r.out, "- %s\n", rs.Name)
+		}
+	}
+	if r.opts.continueOnError {
+		fmt.Fprintln(r.out)
+		w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REPO\tSTAGE\tSTATUS\tERROR")
+		for _, rs := range r.state.Repos {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rs.Name, rs.Stage, rs.status(), rs.Error)
+		}
+		w.Flush()
+	}
+}
+
+// repoVariables returns the variables for the repo with the given name
+// that are available to actions and PR templates.
+func repoVariables(name string) map[string]string {
+	parts := strings.Split(name, "/")
+	return map[string]string{
+		"REPO_OWNER": parts[0],
+		"REPO_NAME":  parts[1],
+	}
+}
+
+// prDescription creates the description of a PR from the results of the actions run on the repo.
+func prDescription(results []action.Result) string {
+	var sb strings.Builder
+	var unchanged []action.Result
+	sb.WriteString("Changes applied by commit-cannon:\n")
+	for _, res := range results {
+		if !res.Changed {
+			unchanged = append(unchanged, res)
+			continue
+		}
+		sb.WriteString("  * ")
+		sb.WriteString(res.Message)
+		sb.WriteByte('\n')
+		if res.Detail != "" {
+			// Indent the detail so it is nested under the list item.
+			for _, line := range strings.Split(res.Detail, "\n") {
+				sb.WriteString("    ")
+				sb.WriteString(line)
+				sb.WriteByte('\n')
+			}
+		}
+	}
+	if len(unchanged) > 0 {
+		sb.WriteString("\nActions that made no changes:\n")
+		for _, res := range unchanged {
+			sb.WriteString("  * ")
+			sb.WriteString(res.Message)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// summarizeResults returns a short summary of the results of the actions run on a repo.
+func summarizeResults(results []action.Result) string {
+	changed := 0
+	files := make(map[string]bool)
+	for _, res := range results {
+		if !res.Changed {
+			continue
+		}
+		changed++
+		for _, f := range res.Files {
+			files[f] = true
+		}
+	}
+	return fmt.Sprintf("%d of %d actions made changes, %d files touched", changed, len(results), len(files))
+}
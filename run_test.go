@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+	"github.com/TouchBistro/cannon/git"
+	"github.com/TouchBistro/goutils/log"
+)
+
+// setupGitConfig creates a global git config with a user so that commits can be made.
+func setupGitConfig(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	gitconfig := filepath.Join(home, ".gitconfig")
+	data := "[user]\n\tname = Cannon Test\n\temail = cannon@example.com\n"
+	if err := os.WriteFile(gitconfig, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write git config: %v", err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+}
+
+// runGit runs a git command in dir and fails the test if it fails.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s: %v", strings.Join(args, " "), out, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newTestRunner creates a runner for the given repos that replaces the title of their README.
+// A remote is created for each repo in remotes, so cloning any other repo fails.
+func newTestRunner(t *testing.T, opts options, repos, remotes []string) (*runner, *bytes.Buffer, string) {
+	t.Helper()
+	setupGitConfig(t)
+	remoteDir := t.TempDir()
+	for _, name := range remotes {
+		src := t.TempDir()
+		runGit(t, src, "init", "-b", "master")
+		if err := os.WriteFile(filepath.Join(src, "README.md"), []byte("# Hype\n"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		runGit(t, src, "add", ".")
+		runGit(t, src, "commit", "-m", "Initial commit")
+		runGit(t, src, "clone", "--bare", src, filepath.Join(remoteDir, name+".git"))
+	}
+	host, err := git.Host{CloneURL: "file://" + filepath.ToSlash(remoteDir) + "/${REPO}.git"}.WithDefaults()
+	if err != nil {
+		t.Fatalf("invalid host: %v", err)
+	}
+
+	var conf config
+	for _, name := range repos {
+		conf.Repos = append(conf.Repos, repoConfig{Name: name, Base: "master", Host: host})
+	}
+	a, err := action.Parse(action.Config{Type: "replaceText", SearchText: "Hype", ApplyText: "Hype Zone", Path: "README.md"})
+	if err != nil {
+		t.Fatalf("failed to parse action: %v", err)
+	}
+	state, err := newRunState("", "hype-hash", "", conf.Repos)
+	if err != nil {
+		t.Fatalf("failed to create run state: %v", err)
+	}
+	var out bytes.Buffer
+	r := &runner{
+		opts:      opts,
+		conf:      conf,
+		actions:   []action.Action{a},
+		state:     state,
+		cannonDir: t.TempDir(),
+		logger:    log.New(log.WithOutput(io.Discard)),
+		out:       &out,
+	}
+	return r, &out, remoteDir
+}
+
+func TestRunDryRun(t *testing.T) {
+	r, out, remoteDir := newTestRunner(t, options{dryRun: true}, []string{"TouchBistro/hype"}, []string{"TouchBistro/hype"})
+	if err := r.run(context.Background()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{"==> TouchBistro/hype\n", "-# Hype\n", "+# Hype Zone\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got output\n\t%s\nwant it to contain\n\t%s", got, want)
+		}
+	}
+	// The changes are discarded and nothing is committed or pushed.
+	repoDir := filepath.Join(r.cannonDir, "TouchBistro/hype")
+	if status := runGit(t, repoDir, "status", "--porcelain"); status != "" {
+		t.Errorf("got changes in repo after dry run\n\t%s", status)
+	}
+	if head, base := runGit(t, repoDir, "rev-parse", "HEAD"), runGit(t, repoDir, "rev-parse", "master"); head != base {
+		t.Errorf("got HEAD at %s, want no commits on top of master at %s", head, base)
+	}
+	remote := filepath.Join(remoteDir, "TouchBistro/hype.git")
+	if branches := runGit(t, remote, "branch", "--list", r.state.Branch); branches != "" {
+		t.Errorf("got branch %s pushed to remote, want nothing pushed", branches)
+	}
+	if rs := r.state.Repos[0]; rs.Stage != stageActionsRun || rs.Commit != "" {
+		t.Errorf("got repo at stage %s with commit %q, want stage %s without a commit", rs.Stage, rs.Commit, stageActionsRun)
+	}
+}
+
+func TestRunContinueOnError(t *testing.T) {
+	opts := options{continueOnError: true, noPR: true, commitMsg: "Make it hype"}
+	// There is no remote for woke so it fails to be prepared.
+	r, out, remoteDir := newTestRunner(t, opts, []string{"TouchBistro/hype", "TouchBistro/woke"}, []string{"TouchBistro/hype"})
+	err := r.run(context.Background())
+	if err == nil || err.Error() != "1 of 2 repos failed" {
+		t.Fatalf("got error %v, want 1 of 2 repos failed", err)
+	}
+
+	// The failure is recorded and the other repo still makes it through every stage.
+	if rs := r.state.Repos[1]; rs.Stage != stagePending || rs.Error == "" {
+		t.Errorf("got woke at stage %s with error %q, want the error recorded at stage %s", rs.Stage, rs.Error, stagePending)
+	}
+	if rs := r.state.Repos[0]; rs.Stage != stagePushed || rs.Error != "" {
+		t.Errorf("got hype at stage %s with error %q, want stage %s without an error", rs.Stage, rs.Error, stagePushed)
+	}
+	remote := filepath.Join(remoteDir, "TouchBistro/hype.git")
+	if got, want := runGit(t, remote, "rev-parse", r.state.Branch), r.state.Repos[0].Commit; got != want {
+		t.Errorf("got remote branch at %s, want %s", got, want)
+	}
+
+	// The summary table lists every repo with its status.
+	want := map[string][]string{
+		"REPO":             {"REPO", "STAGE", "STATUS", "ERROR"},
+		"TouchBistro/hype": {"TouchBistro/hype", "pushed", "ok"},
+		"TouchBistro/woke": {"TouchBistro/woke", "pending", "failed"},
+	}
+	got := make(map[string][]string)
+	for _, line := range strings.Split(out.String(), "\n") {
+		// Only compare the leading columns since errors contain temporary paths.
+		fields := strings.Fields(line)
+		if len(fields) > 0 && want[fields[0]] != nil && len(fields) >= len(want[fields[0]]) {
+			got[fields[0]] = fields[:len(want[fields[0]])]
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got summary table rows %v, want %v\noutput:\n%s", got, want, out.String())
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/TouchBistro/cannon/action"
+)
+
+// stage represents how far a repo has progressed through a run.
+type stage int
+
+const (
+	stagePending stage = iota
+	stagePrepared
+	stageActionsRun
+	stageCommitted
+	stagePushed
+	stagePRCreated
+)
+
+var stageNames = [...]string{
+	stagePending:    "pending",
+	stagePrepared:   "prepared",
+	stageActionsRun: "actions run",
+	stageCommitted:  "committed",
+	stagePushed:     "pushed",
+	stagePRCreated:  "pr created",
+}
+
+func (s stage) String() string {
+	return stageNames[s]
+}
+
+func (s stage) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *stage) UnmarshalText(text []byte) error {
+	for i, name := range stageNames {
+		if name == string(text) {
+			*s = stage(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown stage %q", text)
+}
+
+// runState is the state of a run that is persisted to disk so that
+// an interrupted run can be resumed.
+type runState struct {
+	ID         string       `json:"id"`
+	Branch     string       `json:"branch"`
+	ConfigHash string       `json:"configHash"`
+	Repos      []*repoState `json:"repos"`
+
+	mu   sync.Mutex
+	path string // where the state is persisted; empty means it is not persisted
+}
+
+// repoState is the state of a single repo within a run.
+type repoState struct {
+	Name  string `json:"name"`
+	Stage stage  `json:"stage"`
+	// Skipped is set if the repo did not satisfy the conditions so no actions were run.
+	Skipped bool `json:"skipped,omitempty"`
+	// Unchanged is set if running the actions resulted in no changes.
+	Unchanged bool            `json:"unchanged,omitempty"`
+	Results   []action.Result `json:"results,omitempty"`
+	Commit    string          `json:"commit,omitempty"`
+	PRURL     string          `json:"prURL,omitempty"`
+	// Error is the error that caused the repo to fail at its current stage.
+	Error string `json:"error,omitempty"`
+}
+
+// status returns a short description of the outcome for the repo.
+func (rs *repoState) status() string {
+	switch {
+	case rs.Error != "":
+		return "failed"
+	case rs.Skipped:
+		return "skipped"
+	case rs.Unchanged:
+		return "unchanged"
+	default:
+		return "ok"
+	}
+}
+
+// newRunState creates the state for a new run with a unique ID.
+// If dir is not empty, the state will be persisted to a file within dir.
+// If branch is empty, a unique branch name is generated for the run.
+func newRunState(dir, configHash, branch string, repos []repoConfig) (*runState, error) {
+	// Create a random ID so each run is unique.
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate random run ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+	if branch == "" {
+		branch = "cannon/change-" + id
+	}
+	state := &runState{
+		ID:         id,
+		Branch:     branch,
+		ConfigHash: configHash,
+		Repos:      make([]*repoState, len(repos)),
+	}
+	for i, r := range repos {
+		state.Repos[i] = &repoState{Name: r.Name}
+	}
+	if dir == "" {
+		return state, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	state.path = filepath.Join(dir, id+".json")
+	if err := state.save(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// loadRunState loads the state of the run with the given ID from dir.
+// configHash must match the hash of the config the run was started with.
+func loadRunState(dir, id, configHash string) (*runState, error) {
+	path := filepath.Join(dir, id+".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no run found with ID %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run state file %s: %w", path, err)
+	}
+	state := &runState{path: path}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse run state file %s: %w", path, err)
+	}
+	// The stages that were already completed are only valid for the same config.
+	if state.ConfigHash != configHash {
+		return nil, fmt.Errorf("config has changed since run %s was started", id)
+	}
+	return state, nil
+}
+
+// update calls fn to modify the state and then persists it.
+// It is safe to call update concurrently.
+func (state *runState) update(fn func()) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	fn()
+	return state.save()
+}
+
+// save writes the state to disk. state.mu must be held if there are concurrent updates.
+func (state *runState) save() error {
+	if state.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run state: %w", err)
+	}
+	// Write to a temp file and rename it so the state file is never partially written
+	// if cannon is killed in the middle of saving.
+	tmp := state.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write run state file %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, state.path); err != nil {
+		return fmt.Errorf("failed to save run state file %s: %w", state.path, err)
+	}
+	return nil
+}
+
+// clearErrors clears the errors of all repos so that repos that failed
+// in a previous attempt are retried from the stage they failed at.
+func (state *runState) clearErrors() error {
+	return state.update(func() {
+		for _, rs := range state.Repos {
+			rs.Error = ""
+		}
+	})
+}
+
+// selectRepos returns the indices of the repos for which pred returns true.
+func (state *runState) selectRepos(pred func(rs *repoState) bool) []int {
+	var indices []int
+	for i, rs := range state.Repos {
+		if pred(rs) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
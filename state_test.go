@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TouchBistro/cannon/action"
+	"github.com/TouchBistro/goutils/progress"
+)
+
+func TestRepoStateStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		rs   repoState
+		want string
+	}{
+		{name: "ok", rs: repoState{Stage: stagePRCreated}, want: "ok"},
+		{name: "skipped", rs: repoState{Stage: stageActionsRun, Skipped: true}, want: "skipped"},
+		{name: "unchanged", rs: repoState{Stage: stageActionsRun, Unchanged: true}, want: "unchanged"},
+		{name: "failed", rs: repoState{Stage: stageCommitted, Unchanged: true, Error: "push rejected"}, want: "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rs.status(); got != tt.want {
+				t.Errorf("got status %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStageText(t *testing.T) {
+	for s := stagePending; s <= stagePRCreated; s++ {
+		text, err := s.MarshalText()
+		if err != nil {
+			t.Fatalf("failed to marshal stage %s: %v", s, err)
+		}
+		var got stage
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatalf("failed to unmarshal stage %s: %v", text, err)
+		}
+		if got != s {
+			t.Errorf("got stage %s, want %s", got, s)
+		}
+	}
+
+	var s stage
+	if err := s.UnmarshalText([]byte("merged")); err == nil {
+		t.Error("want error for unknown stage, got nil")
+	}
+}
+
+func TestRunStateSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	repos := []repoConfig{{Name: "TouchBistro/hype"}, {Name: "TouchBistro/woke"}}
+	state, err := newRunState(dir, "hype-hash", "", repos)
+	if err != nil {
+		t.Fatalf("failed to create run state: %v", err)
+	}
+	if want := "cannon/change-" + state.ID; state.Branch != want {
+		t.Errorf("got branch %s, want %s", state.Branch, want)
+	}
+	err = state.update(func() {
+		state.Repos[0].Stage = stagePRCreated
+		state.Repos[0].Results = []action.Result{{Message: "Set `version` to `1.1.0`", Files: []string{"package.json"}, Matches: 1, Changed: true}}
+		state.Repos[0].Commit = "d2c8a3f"
+		state.Repos[0].PRURL = "https://github.com/TouchBistro/hype/pull/1"
+		state.Repos[1].Stage = stagePushed
+		state.Repos[1].Error = "failed to create pull request"
+	})
+	if err != nil {
+		t.Fatalf("failed to update run state: %v", err)
+	}
+
+	got, err := loadRunState(dir, state.ID, "hype-hash")
+	if err != nil {
+		t.Fatalf("failed to load run state: %v", err)
+	}
+	if got.ID != state.ID || got.Branch != state.Branch || got.ConfigHash != state.ConfigHash {
+		t.Errorf("got run %s on branch %s with hash %s, want run %s on branch %s with hash %s",
+			got.ID, got.Branch, got.ConfigHash, state.ID, state.Branch, state.ConfigHash)
+	}
+	if !reflect.DeepEqual(got.Repos, state.Repos) {
+		t.Errorf("got repos %+v, want %+v", got.Repos, state.Repos)
+	}
+
+	// A fixed branch is used as is.
+	fixed, err := newRunState("", "hype-hash", "cannon/stable", repos)
+	if err != nil {
+		t.Fatalf("failed to create run state: %v", err)
+	}
+	if fixed.Branch != "cannon/stable" {
+		t.Errorf("got branch %s, want cannon/stable", fixed.Branch)
+	}
+}
+
+func TestLoadRunStateError(t *testing.T) {
+	dir := t.TempDir()
+	state, err := newRunState(dir, "hype-hash", "", []repoConfig{{Name: "TouchBistro/hype"}})
+	if err != nil {
+		t.Fatalf("failed to create run state: %v", err)
+	}
+	tests := []struct {
+		name       string
+		id         string
+		configHash string
+		wantErr    string
+	}{
+		{name: "missing run", id: "deadbeef", configHash: "hype-hash", wantErr: "no run found with ID deadbeef"},
+		{name: "config changed", id: state.ID, configHash: "woke-hash", wantErr: "config has changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadRunState(dir, tt.id, tt.configHash)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunStageResume(t *testing.T) {
+	dir := t.TempDir()
+	repos := []repoConfig{{Name: "TouchBistro/hype"}, {Name: "TouchBistro/woke"}, {Name: "TouchBistro/lit"}, {Name: "TouchBistro/meh"}}
+	state, err := newRunState(dir, "hype-hash", "", repos)
+	if err != nil {
+		t.Fatalf("failed to create run state: %v", err)
+	}
+	state.Repos[0].Stage = stagePRCreated
+	state.Repos[1].Stage = stageCommitted
+	state.Repos[1].Error = "push rejected"
+	state.Repos[2].Stage = stageActionsRun
+	state.Repos[2].Unchanged = true
+	state.Repos[3].Stage = stageCommitted
+	r := runner{state: state, opts: options{continueOnError: true}}
+
+	// Select the repos that still need to be pushed like the push stage does.
+	needsPush := func(rs *repoState) bool {
+		return rs.Stage == stageCommitted || rs.Stage == stagePushed
+	}
+	var mu sync.Mutex
+	var got []string
+	runPush := func() error {
+		got = nil
+		return r.runStage(context.Background(), progress.RunParallelOptions{Message: "Pushing", Concurrency: 2}, needsPush, func(ctx context.Context, ri int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, state.Repos[ri].Name)
+			if ri == 3 {
+				return errors.New("remote hung up")
+			}
+			return nil
+		})
+	}
+
+	// Failed repos are not selected until their errors are cleared.
+	if err := runPush(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := []string{"TouchBistro/meh"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got repos %v, want %v", got, want)
+	}
+	if state.Repos[3].Error != "remote hung up" {
+		t.Errorf("got error %q, want the error to be recorded", state.Repos[3].Error)
+	}
+
+	// Resuming retries the failed repos from the stage they failed at.
+	resumed, err := loadRunState(dir, state.ID, "hype-hash")
+	if err != nil {
+		t.Fatalf("failed to load run state: %v", err)
+	}
+	if err := resumed.clearErrors(); err != nil {
+		t.Fatalf("failed to clear errors: %v", err)
+	}
+	state = resumed
+	r.state = resumed
+	if err := runPush(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// The stage runs concurrently so the order is not deterministic.
+	sort.Strings(got)
+	if want := []string{"TouchBistro/meh", "TouchBistro/woke"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got repos %v, want %v", got, want)
+	}
+}